@@ -32,7 +32,21 @@ type Cluster struct {
 
 // Validate checks the cluster and permissions for a cluster JWT
 func (c *Cluster) Validate(vr *ValidationResults) {
-	// fixme validate cluster data
+	for _, a := range c.Accounts {
+		if !nkeys.IsValidPublicAccountKey(a) {
+			vr.AddError("%q is not an account public key", a)
+		}
+	}
+}
+
+// HasAccount returns true if the account is permitted in the cluster
+func (c *Cluster) HasAccount(pk string) bool {
+	for _, a := range c.Accounts {
+		if a == pk {
+			return true
+		}
+	}
+	return false
 }
 
 // ClusterClaims defines the data in a cluster JWT
@@ -129,3 +129,25 @@ func TestClusterType(t *testing.T) {
 	}
 
 }
+
+func TestClusterAccounts(t *testing.T) {
+	ckp := createClusterNKey(t)
+	apk := publicKey(createAccountNKey(t), t)
+
+	c := NewClusterClaims(publicKey(ckp, t))
+	c.Accounts = append(c.Accounts, apk)
+	vr := CreateValidationResults()
+	c.Validate(vr)
+	if !vr.IsEmpty() {
+		t.Fatalf("expected cluster to validate cleanly: %v", vr.Issues[0])
+	}
+	AssertEquals(true, c.HasAccount(apk), t)
+	AssertEquals(false, c.HasAccount(publicKey(createAccountNKey(t), t)), t)
+
+	c.Accounts = append(c.Accounts, publicKey(createUserNKey(t), t))
+	vr = CreateValidationResults()
+	c.Validate(vr)
+	if !vr.IsBlocking(false) {
+		t.Fatal("expected malformed account key to be blocking")
+	}
+}
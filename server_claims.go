@@ -34,6 +34,18 @@ func (s *Server) Validate(vr *ValidationResults) {
 	}
 }
 
+// AuthorizesAccount returns true if the server may serve the account. When the
+// claims of the server's cluster are provided, the account has to be listed in it.
+func (s *Server) AuthorizesAccount(pk string, cluster *ClusterClaims) bool {
+	if cluster == nil {
+		return true
+	}
+	if s.Cluster != cluster.Subject {
+		return false
+	}
+	return cluster.HasAccount(pk)
+}
+
 // Deprecated: ServerClaims are not supported
 type ServerClaims struct {
 	ClaimsData
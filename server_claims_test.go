@@ -129,3 +129,25 @@ func TestServerType(t *testing.T) {
 	}
 
 }
+
+func TestServerAuthorizesClusterAccounts(t *testing.T) {
+	ckp := createClusterNKey(t)
+	member := publicKey(createAccountNKey(t), t)
+	other := publicKey(createAccountNKey(t), t)
+
+	cc := NewClusterClaims(publicKey(ckp, t))
+	cc.Accounts = append(cc.Accounts, member)
+
+	sc := NewServerClaims(publicKey(createServerNKey(t), t))
+	sc.Cluster = cc.Subject
+
+	// without cluster claims the server isn't restricted
+	AssertEquals(true, sc.AuthorizesAccount(other, nil), t)
+
+	AssertEquals(true, sc.AuthorizesAccount(member, cc), t)
+	AssertEquals(false, sc.AuthorizesAccount(other, cc), t)
+
+	// claims of a different cluster don't apply
+	sc.Cluster = publicKey(createClusterNKey(t), t)
+	AssertEquals(false, sc.AuthorizesAccount(member, cc), t)
+}
@@ -17,6 +17,8 @@ package jwt
 
 import (
 	"errors"
+	"fmt"
+	"regexp"
 	"sort"
 	"time"
 
@@ -225,6 +227,8 @@ type Account struct {
 	DefaultPermissions Permissions           `json:"default_permissions,omitempty"`
 	Mappings           Mapping               `json:"mappings,omitempty"`
 	Authorization      ExternalAuthorization `json:"authorization,omitempty"`
+	BlockedSubjects    StringList            `json:"blocked_subjects,omitempty"`
+	ExemptLimits       StringList            `json:"exempt_limits,omitempty"`
 	Info
 	GenericFields
 }
@@ -233,10 +237,21 @@ type Account struct {
 func (a *Account) Validate(acct *AccountClaims, vr *ValidationResults) {
 	a.Imports.Validate(acct.Subject, vr)
 	a.Exports.Validate(vr)
+	if err := a.validateSelfImports(acct.Subject); err != nil {
+		vr.AddError(err.Error())
+	}
 	a.Limits.Validate(vr)
 	a.DefaultPermissions.Validate(vr)
 	a.Mappings.Validate(vr)
 	a.Authorization.Validate(vr)
+	for _, b := range a.BlockedSubjects {
+		Subject(b).Validate(vr)
+	}
+	for _, l := range a.ExemptLimits {
+		if !knownLimitNames[l] {
+			vr.AddError("exempt limit %q is not a known limit", l)
+		}
+	}
 
 	if !a.Limits.IsEmpty() && a.Limits.Imports >= 0 && int64(len(a.Imports)) > a.Limits.Imports {
 		vr.AddError("the account contains more imports than allowed by the operator")
@@ -265,6 +280,143 @@ func (a *Account) Validate(acct *AccountClaims, vr *ValidationResults) {
 	a.Info.Validate(vr)
 }
 
+// subjectMacroRE matches the {{var}} macros ExpandEnvSubjects replaces
+var subjectMacroRE = regexp.MustCompile(`\{\{\s*([\w\-]+)\s*\}\}`)
+
+func expandSubjectMacros(subject string, env map[string]string) (string, error) {
+	var err error
+	expanded := subjectMacroRE.ReplaceAllStringFunc(subject, func(m string) string {
+		name := subjectMacroRE.FindStringSubmatch(m)[1]
+		v, ok := env[name]
+		if !ok && err == nil {
+			err = fmt.Errorf("subject %q references undefined variable %q", subject, name)
+		}
+		return v
+	})
+	return expanded, err
+}
+
+// ExpandEnvSubjects replaces {{var}} macros in the subjects of the account's
+// imports and exports with the values from env. If a macro can't be resolved
+// an error is returned and the account is not modified.
+func ExpandEnvSubjects(account *Account, env map[string]string) error {
+	type update struct {
+		target *string
+		value  string
+	}
+	var updates []update
+	expand := func(target *string) error {
+		v, err := expandSubjectMacros(*target, env)
+		if err != nil {
+			return err
+		}
+		updates = append(updates, update{target, v})
+		return nil
+	}
+	for _, i := range account.Imports {
+		if i == nil {
+			continue
+		}
+		for _, s := range []*Subject{&i.Subject, &i.To} {
+			if err := expand((*string)(s)); err != nil {
+				return err
+			}
+		}
+		if err := expand((*string)(&i.LocalSubject)); err != nil {
+			return err
+		}
+	}
+	for _, e := range account.Exports {
+		if e == nil {
+			continue
+		}
+		if err := expand((*string)(&e.Subject)); err != nil {
+			return err
+		}
+	}
+	for _, u := range updates {
+		*u.target = u.value
+	}
+	return nil
+}
+
+// SortedImports returns a copy of the imports with services before streams,
+// each ordered by subject and then by account. The account is not modified.
+func (a *Account) SortedImports() Imports {
+	sorted := make(Imports, len(a.Imports))
+	copy(sorted, a.Imports)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		x, y := sorted[i], sorted[j]
+		if x.IsService() != y.IsService() {
+			return x.IsService()
+		}
+		if x.Subject != y.Subject {
+			return x.Subject < y.Subject
+		}
+		return x.Account < y.Account
+	})
+	return sorted
+}
+
+// knownLimitNames are the limits that can be named in Account.ExemptLimits,
+// using their json names
+var knownLimitNames = map[string]bool{
+	"subs":                  true,
+	"data":                  true,
+	"payload":               true,
+	"imports":               true,
+	"exports":               true,
+	"conn":                  true,
+	"leaf":                  true,
+	"mem_storage":           true,
+	"disk_storage":          true,
+	"streams":               true,
+	"consumer":              true,
+	"max_ack_pending":       true,
+	"mem_max_stream_bytes":  true,
+	"disk_max_stream_bytes": true,
+}
+
+// IsExempt returns true if the named limit is not enforced for the account
+func (a *Account) IsExempt(name string) bool {
+	return a.ExemptLimits.Contains(name)
+}
+
+// IsBlocked returns true if the subject is blocked for all users of the account,
+// regardless of their permissions. A wildcard subject is blocked if all the
+// subjects it matches are blocked.
+func (a *Account) IsBlocked(subject string) bool {
+	var blocked SubjectSet
+	for _, b := range a.BlockedSubjects {
+		// invalid entries are reported by Validate
+		if s, err := NewSubjectSet(b); err == nil {
+			blocked = blocked.Union(s)
+		}
+	}
+	return blocked.Contains(subject)
+}
+
+// validateSelfImports checks that imports from the account itself are backed
+// by one of its own exports.
+func (a *Account) validateSelfImports(acctPubKey string) error {
+	for _, i := range a.Imports {
+		if i == nil || i.Account == "" || i.Account != acctPubKey {
+			continue
+		}
+		found := false
+		for _, e := range a.Exports {
+			if e != nil && e.Type == i.Type && patternCovers(string(e.Subject), string(i.Subject)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("import %q from the account itself has no matching %s export", i.Subject, i.Type)
+		}
+	}
+	return nil
+}
+
 // AccountClaims defines the body of an account JWT
 type AccountClaims struct {
 	ClaimsData
@@ -287,6 +439,7 @@ func NewAccountClaims(subject string) *AccountClaims {
 		JetStreamTieredLimits{},
 	}
 	c.Subject = subject
+	c.Expires = defaultExpires(AccountClaim)
 	c.Mappings = Mapping{}
 	return c
 }
@@ -354,6 +507,41 @@ func (a *AccountClaims) Claims() *ClaimsData {
 	return &a.ClaimsData
 }
 
+// SelfConsistent returns an error if the account imports from itself
+// without a local export covering the imported subject.
+func (a *AccountClaims) SelfConsistent() error {
+	return a.Account.validateSelfImports(a.Subject)
+}
+
+// AddImportWithActivation attaches the activation token to the import and adds
+// it to the account. The import, including the activation, is validated first:
+// the activation has to be issued by the exporting account for this account,
+// cover the import subject and not be expired. On error the import isn't added.
+func (a *AccountClaims) AddImportWithActivation(imp Import, activationToken string) error {
+	if activationToken == "" {
+		return errors.New("activation token is required")
+	}
+	act, err := DecodeActivationClaims(activationToken)
+	if err != nil {
+		return fmt.Errorf("invalid activation token for import %q: %v", imp.Subject, err)
+	}
+	vr := CreateValidationResults()
+	act.Validate(vr)
+	for _, i := range vr.Issues {
+		if i.Blocking || i.TimeCheck {
+			return fmt.Errorf("invalid activation token for import %q: %s", imp.Subject, i.Description)
+		}
+	}
+	imp.Token = activationToken
+	vr = CreateValidationResults()
+	imp.Validate(a.Subject, vr)
+	if vr.IsBlocking(false) {
+		return vr.Errors()[0]
+	}
+	a.Imports.Add(&imp)
+	return nil
+}
+
 // DidSign checks the claims against the account's public key and its signing keys
 func (a *AccountClaims) DidSign(uc Claims) bool {
 	if uc != nil {
@@ -761,3 +761,190 @@ func TestAccountExternalAuthorizationRequiresOneUser(t *testing.T) {
 		vr.Errors()[0].Error(),
 		t)
 }
+
+func TestAccountSelfImport(t *testing.T) {
+	akp := createAccountNKey(t)
+	apk := publicKey(akp, t)
+
+	account := NewAccountClaims(apk)
+	account.Imports.Add(&Import{Subject: "foo.bar", Account: apk, LocalSubject: "local.bar", Type: Stream})
+
+	if err := account.SelfConsistent(); err == nil {
+		t.Fatal("expected self import without export to fail")
+	}
+	vr := CreateValidationResults()
+	account.Validate(vr)
+	if !vr.IsBlocking(false) {
+		t.Fatal("expected self import without export to be blocking")
+	}
+
+	// an export of a different type doesn't satisfy the import
+	account.Exports.Add(&Export{Subject: "foo.*", Type: Service})
+	if err := account.SelfConsistent(); err == nil {
+		t.Fatal("expected self import with service export to fail")
+	}
+
+	// a * export doesn't satisfy a > import
+	wide := NewAccountClaims(apk)
+	wide.Imports.Add(&Import{Subject: "foo.>", Account: apk, LocalSubject: "local.>", Type: Stream})
+	wide.Exports.Add(&Export{Subject: "foo.*", Type: Stream})
+	if err := wide.SelfConsistent(); err == nil {
+		t.Fatal("expected self import of foo.> with foo.* export to fail")
+	}
+
+	account.Exports.Add(&Export{Subject: "foo.>", Type: Stream})
+	AssertNoError(account.SelfConsistent(), t)
+	vr = CreateValidationResults()
+	account.Validate(vr)
+	if !vr.IsEmpty() {
+		t.Fatalf("expected self import with export to validate: %v", vr.Issues[0])
+	}
+
+	actJwt := encode(account, akp, t)
+	_, err := DecodeAccountClaims(actJwt)
+	AssertNoError(err, t)
+}
+
+func TestAccountBlockedSubjects(t *testing.T) {
+	akp := createAccountNKey(t)
+	account := NewAccountClaims(publicKey(akp, t))
+	account.BlockedSubjects.Add("$SYS.>", "secret")
+
+	user := NewUserClaims(publicKey(createUserNKey(t), t))
+	user.Pub.Allow.Add("$SYS.>")
+
+	for _, s := range user.Pub.Allow {
+		AssertTrue(account.IsBlocked(s), t)
+	}
+	AssertTrue(account.IsBlocked("$SYS.REQ.SERVER.PING"), t)
+	AssertTrue(account.IsBlocked("secret"), t)
+	AssertFalse(account.IsBlocked("secret.foo"), t)
+	AssertFalse(account.IsBlocked("orders"), t)
+	// only partially blocked
+	AssertFalse(account.IsBlocked(">"), t)
+
+	// a > isn't blocked by a *
+	wild := NewAccountClaims(publicKey(akp, t))
+	wild.BlockedSubjects.Add("$SYS.*")
+	AssertTrue(wild.IsBlocked("$SYS.*"), t)
+	AssertTrue(wild.IsBlocked("$SYS.REQ"), t)
+	AssertFalse(wild.IsBlocked("$SYS.>"), t)
+	// blocked subjects together can block a wildcard
+	wild.BlockedSubjects.Add("$SYS.*.>")
+	AssertTrue(wild.IsBlocked("$SYS.>"), t)
+
+	token := encode(account, createOperatorNKey(t), t)
+	ac, err := DecodeAccountClaims(token)
+	AssertNoError(err, t)
+	AssertEquals(2, len(ac.BlockedSubjects), t)
+	AssertTrue(ac.IsBlocked("$SYS.>"), t)
+
+	account.BlockedSubjects.Add("bad subject")
+	vr := CreateValidationResults()
+	account.Validate(vr)
+	AssertTrue(vr.IsBlocking(false), t)
+}
+
+func TestAccountExemptLimits(t *testing.T) {
+	akp := createAccountNKey(t)
+	account := NewAccountClaims(publicKey(akp, t))
+	account.Limits.Payload = 1024
+	account.Limits.Conn = 10
+	account.ExemptLimits.Add("payload")
+
+	AssertTrue(account.IsExempt("payload"), t)
+	AssertFalse(account.IsExempt("conn"), t)
+
+	token := encode(account, createOperatorNKey(t), t)
+	ac, err := DecodeAccountClaims(token)
+	AssertNoError(err, t)
+	AssertTrue(ac.IsExempt("payload"), t)
+	AssertFalse(ac.IsExempt("conn"), t)
+
+	account.ExemptLimits.Add("payloads")
+	vr := CreateValidationResults()
+	account.Validate(vr)
+	AssertTrue(vr.IsBlocking(false), t)
+}
+
+func TestAccountAddImportWithActivation(t *testing.T) {
+	exporter := createAccountNKey(t)
+	epk := publicKey(exporter, t)
+	apk := publicKey(createAccountNKey(t), t)
+	account := NewAccountClaims(apk)
+
+	activation := NewActivationClaims(apk)
+	activation.ImportSubject = "orders.>"
+	activation.ImportType = Service
+	token := encode(activation, exporter, t)
+
+	// wrong subject
+	err := account.AddImportWithActivation(Import{Subject: "billing", Account: epk, Type: Service}, token)
+	AssertTrue(err != nil, t)
+	// wrong type
+	err = account.AddImportWithActivation(Import{Subject: "orders.new", Account: epk, Type: Stream}, token)
+	AssertTrue(err != nil, t)
+	// wrong exporter
+	err = account.AddImportWithActivation(Import{Subject: "orders.new", Account: publicKey(createAccountNKey(t), t), Type: Service}, token)
+	AssertTrue(err != nil, t)
+	// activation for another account
+	other := NewAccountClaims(publicKey(createAccountNKey(t), t))
+	AssertTrue(other.AddImportWithActivation(Import{Subject: "orders.new", Account: epk, Type: Service}, token) != nil, t)
+	// not a token
+	err = account.AddImportWithActivation(Import{Subject: "orders.new", Account: epk, Type: Service}, "bad")
+	AssertTrue(err != nil, t)
+
+	expired := NewActivationClaims(apk)
+	expired.ImportSubject = "orders.>"
+	expired.ImportType = Service
+	expired.Expires = time.Now().Add(-time.Hour).Unix()
+	err = account.AddImportWithActivation(Import{Subject: "orders.new", Account: epk, Type: Service}, encode(expired, exporter, t))
+	AssertTrue(err != nil, t)
+	AssertEquals(0, len(account.Imports), t)
+	AssertEquals(0, len(other.Imports), t)
+
+	AssertNoError(account.AddImportWithActivation(Import{Subject: "orders.new", Account: epk, Type: Service}, token), t)
+	AssertEquals(1, len(account.Imports), t)
+	AssertEquals(token, account.Imports[0].Token, t)
+}
+
+func TestAccountSortedImports(t *testing.T) {
+	a := &Account{}
+	a.Imports.Add(&Import{Subject: "b", Account: "B", Type: Stream},
+		&Import{Subject: "z", Account: "A", Type: Service},
+		&Import{Subject: "a", Account: "B", Type: Stream},
+		&Import{Subject: "c", Account: "A", Type: Service},
+		&Import{Subject: "a", Account: "A", Type: Stream})
+
+	sorted := a.SortedImports()
+	AssertEquals(5, len(sorted), t)
+	expected := []string{"c A", "z A", "a A", "a B", "b B"}
+	for i, e := range expected {
+		AssertEquals(e, string(sorted[i].Subject)+" "+sorted[i].Account, t)
+	}
+	// the account keeps its order
+	AssertEquals(Subject("b"), a.Imports[0].Subject, t)
+}
+
+func TestExpandEnvSubjects(t *testing.T) {
+	epk := publicKey(createAccountNKey(t), t)
+	a := &Account{}
+	a.Imports.Add(&Import{Subject: "{{env}}.orders.>", Account: epk, Type: Stream, LocalSubject: "{{ env }}.{{team}}.orders.>"},
+		&Import{Subject: "billing", Account: epk, Type: Service})
+	a.Exports.Add(&Export{Subject: "{{env}}.events.*", Type: Stream})
+
+	AssertNoError(ExpandEnvSubjects(a, map[string]string{"env": "prod", "team": "red"}), t)
+	AssertEquals(Subject("prod.orders.>"), a.Imports[0].Subject, t)
+	AssertEquals(RenamingSubject("prod.red.orders.>"), a.Imports[0].LocalSubject, t)
+	AssertEquals(Subject("billing"), a.Imports[1].Subject, t)
+	AssertEquals(Subject("prod.events.*"), a.Exports[0].Subject, t)
+
+	b := &Account{}
+	b.Imports.Add(&Import{Subject: "{{env}}.orders.>", Account: epk, Type: Stream})
+	b.Exports.Add(&Export{Subject: "{{region}}.events", Type: Stream})
+	err := ExpandEnvSubjects(b, map[string]string{"env": "prod"})
+	AssertTrue(err != nil, t)
+	// nothing is expanded if a variable is missing
+	AssertEquals(Subject("{{env}}.orders.>"), b.Imports[0].Subject, t)
+	AssertEquals(Subject("{{region}}.events"), b.Exports[0].Subject, t)
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright 2023 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwt
+
+import (
+	"errors"
+
+	"github.com/nats-io/nkeys"
+)
+
+// AccountList holds the set of account public keys considered valid by an operator
+type AccountList struct {
+	Accounts StringList `json:"accounts,omitempty"`
+	GenericFields
+}
+
+// Validate checks that all entries are account public keys
+func (a *AccountList) Validate(vr *ValidationResults) {
+	for _, k := range a.Accounts {
+		if !nkeys.IsValidPublicAccountKey(k) {
+			vr.AddError("%q is not an account public key", k)
+		}
+	}
+}
+
+// AccountListClaims is a signed roster of accounts issued by an operator
+type AccountListClaims struct {
+	ClaimsData
+	AccountList `json:"nats,omitempty"`
+}
+
+// NewAccountListClaims creates an account list for the operator with the specified public key
+func NewAccountListClaims(subject string) *AccountListClaims {
+	if subject == "" {
+		return nil
+	}
+	c := &AccountListClaims{}
+	c.Subject = subject
+	return c
+}
+
+// Encode the claims into a JWT string
+func (al *AccountListClaims) Encode(pair nkeys.KeyPair) (string, error) {
+	if !nkeys.IsValidPublicOperatorKey(al.Subject) {
+		return "", errors.New("expected subject to be an operator public key")
+	}
+	al.Type = AccountListClaim
+	return al.ClaimsData.encode(pair, al)
+}
+
+// DecodeAccountListClaims tries to create account list claims from a JWT string
+func DecodeAccountListClaims(token string) (*AccountListClaims, error) {
+	claims, err := Decode(token)
+	if err != nil {
+		return nil, err
+	}
+	al, ok := claims.(*AccountListClaims)
+	if !ok {
+		return nil, errors.New("not account list claim")
+	}
+	return al, nil
+}
+
+func (al *AccountListClaims) ClaimType() ClaimType {
+	return al.Type
+}
+
+func (al *AccountListClaims) String() string {
+	return al.ClaimsData.String(al)
+}
+
+// Payload returns the account list specific data
+func (al *AccountListClaims) Payload() interface{} {
+	return &al.AccountList
+}
+
+// Validate the contents of the claims
+func (al *AccountListClaims) Validate(vr *ValidationResults) {
+	al.ClaimsData.Validate(vr)
+	al.AccountList.Validate(vr)
+	if al.Issuer != "" && !nkeys.IsValidPublicOperatorKey(al.Issuer) {
+		vr.AddError("account lists must be issued by an operator")
+	}
+}
+
+// ExpectedPrefixes defines the nkey types that can sign account list claims, operator
+func (al *AccountListClaims) ExpectedPrefixes() []nkeys.PrefixByte {
+	return []nkeys.PrefixByte{nkeys.PrefixByteOperator}
+}
+
+// Claims returns the generic claims data
+func (al *AccountListClaims) Claims() *ClaimsData {
+	return &al.ClaimsData
+}
+
+func (al *AccountListClaims) updateVersion() {
+	al.GenericFields.Version = libVersion
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright 2023 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAccountListClaims(t *testing.T) {
+	okp := createOperatorNKey(t)
+	opk := publicKey(okp, t)
+	apk1 := publicKey(createAccountNKey(t), t)
+	apk2 := publicKey(createAccountNKey(t), t)
+
+	al := NewAccountListClaims(opk)
+	al.Expires = time.Now().Add(time.Hour).UTC().Unix()
+	al.Accounts.Add(apk1, apk2)
+	token := encode(al, okp, t)
+
+	al2, err := DecodeAccountListClaims(token)
+	AssertNoError(err, t)
+	AssertEquals(al.String(), al2.String(), t)
+	AssertEquals(ClaimType(AccountListClaim), al2.ClaimType(), t)
+	AssertEquals(opk, al2.Issuer, t)
+	AssertTrue(al2.Accounts.Contains(apk1), t)
+	AssertTrue(al2.Accounts.Contains(apk2), t)
+
+	vr := CreateValidationResults()
+	al2.Validate(vr)
+	if !vr.IsEmpty() {
+		t.Fatalf("expected account list to validate: %v", vr.Issues[0])
+	}
+
+	c, err := Decode(token)
+	AssertNoError(err, t)
+	if _, ok := c.(*AccountListClaims); !ok {
+		t.Fatal("expected Decode to return account list claims")
+	}
+}
+
+func TestAccountListClaimsIssuer(t *testing.T) {
+	opk := publicKey(createOperatorNKey(t), t)
+	al := NewAccountListClaims(opk)
+	al.Accounts.Add(publicKey(createAccountNKey(t), t))
+
+	if _, err := al.Encode(createAccountNKey(t)); err == nil {
+		t.Fatal("expected account list signed by an account to fail")
+	}
+
+	al = NewAccountListClaims(publicKey(createAccountNKey(t), t))
+	if _, err := al.Encode(createOperatorNKey(t)); err == nil {
+		t.Fatal("expected account list with account subject to fail")
+	}
+
+	if NewAccountListClaims("") != nil {
+		t.Fatal("expected nil account list claims")
+	}
+}
+
+func TestAccountListClaimsInvalidAccount(t *testing.T) {
+	okp := createOperatorNKey(t)
+	al := NewAccountListClaims(publicKey(okp, t))
+	al.Accounts.Add(publicKey(createAccountNKey(t), t), publicKey(createUserNKey(t), t))
+
+	al2, err := DecodeAccountListClaims(encode(al, okp, t))
+	AssertNoError(err, t)
+	vr := CreateValidationResults()
+	al2.Validate(vr)
+	if !vr.IsBlocking(false) {
+		t.Fatal("expected user key in account list to be blocking")
+	}
+}
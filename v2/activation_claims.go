@@ -67,6 +67,7 @@ func NewActivationClaims(subject string) *ActivationClaims {
 	}
 	ac := &ActivationClaims{}
 	ac.Subject = subject
+	ac.Expires = defaultExpires(ActivationClaim)
 	return ac
 }
 
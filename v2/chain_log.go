@@ -0,0 +1,65 @@
+/*
+ * Copyright 2023 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwt
+
+import (
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ChainEntry is an entry of a tamper evident log of issued claims
+type ChainEntry struct {
+	Claims Claims
+	// Hash is the ChainHash of the claims and the hash of the previous entry
+	Hash string
+}
+
+// ChainHash returns the hash linking the claim to the hash of the previous log
+// entry. The first entry of a log uses an empty prev.
+func ChainHash(prev string, c Claims) (string, error) {
+	if c == nil {
+		return "", errors.New("claims are required")
+	}
+	j, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	h := sha512.New512_256()
+	h.Write([]byte(prev))
+	h.Write([]byte{0})
+	h.Write(j)
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(h.Sum(nil)), nil
+}
+
+// VerifyChainLog checks that each entry's hash links its claims to the entry
+// before it. The error names the first entry that doesn't verify.
+func VerifyChainLog(entries []ChainEntry) error {
+	prev := ""
+	for i, e := range entries {
+		h, err := ChainHash(prev, e.Claims)
+		if err != nil {
+			return fmt.Errorf("chain entry %d: %v", i, err)
+		}
+		if h != e.Hash {
+			return fmt.Errorf("chain entry %d: hash doesn't match", i)
+		}
+		prev = h
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright 2023 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwt
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChainLog(t *testing.T) {
+	var entries []ChainEntry
+	prev := ""
+	for i := 0; i < 3; i++ {
+		uc := NewUserClaims(publicKey(createUserNKey(t), t))
+		h, err := ChainHash(prev, uc)
+		AssertNoError(err, t)
+		entries = append(entries, ChainEntry{Claims: uc, Hash: h})
+		prev = h
+	}
+	AssertNoError(VerifyChainLog(entries), t)
+	AssertNoError(VerifyChainLog(nil), t)
+
+	// the hash depends on the previous entry
+	h, err := ChainHash("other", entries[0].Claims)
+	AssertNoError(err, t)
+	AssertTrue(h != entries[0].Hash, t)
+
+	entries[1].Claims.Claims().Name = "altered"
+	err = VerifyChainLog(entries)
+	AssertTrue(err != nil && strings.Contains(err.Error(), "entry 1"), t)
+
+	// recomputing the altered entry's hash breaks the link to the next entry
+	entries[1].Hash, err = ChainHash(entries[0].Hash, entries[1].Claims)
+	AssertNoError(err, t)
+	err = VerifyChainLog(entries)
+	AssertTrue(err != nil && strings.Contains(err.Error(), "entry 2"), t)
+
+	_, err = ChainHash("", nil)
+	AssertTrue(err != nil, t)
+}
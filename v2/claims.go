@@ -22,6 +22,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/nats-io/nkeys"
@@ -43,10 +44,34 @@ const (
 	AuthorizationRequestClaim = "authorization_request"
 	// AuthorizationResponseClaim is the type of an auth response claim JWT
 	AuthorizationResponseClaim = "authorization_response"
+	// AccountListClaim is the type of an operator signed list of accounts
+	AccountListClaim = "account_list"
 	// GenericClaim is a type that doesn't match Operator/Account/User/ActionClaim
 	GenericClaim = "generic"
 )
 
+// DefaultExpiries holds the lifetime applied by the claim constructors,
+// keyed by claim type. A missing or zero entry means the claim doesn't expire.
+var DefaultExpiries = map[string]time.Duration{
+	OperatorClaim:   10 * 365 * 24 * time.Hour,
+	AccountClaim:    365 * 24 * time.Hour,
+	ActivationClaim: 30 * 24 * time.Hour,
+	UserClaim:       24 * time.Hour,
+}
+
+// DefaultExpiry returns the default lifetime for claims of the specified type
+func DefaultExpiry(claimType string) time.Duration {
+	return DefaultExpiries[claimType]
+}
+
+// defaultExpires returns the expiration for a claim of the specified type created now
+func defaultExpires(claimType string) int64 {
+	if d := DefaultExpiry(claimType); d > 0 {
+		return time.Now().Add(d).UTC().Unix()
+	}
+	return 0
+}
+
 func IsGenericClaimType(s string) bool {
 	switch s {
 	case OperatorClaim:
@@ -59,6 +84,8 @@ func IsGenericClaimType(s string) bool {
 		fallthrough
 	case AuthorizationResponseClaim:
 		fallthrough
+	case AccountListClaim:
+		fallthrough
 	case ActivationClaim:
 		return false
 	case GenericClaim:
@@ -222,6 +249,56 @@ func (c *ClaimsData) hash() (string, error) {
 	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(h.Sum(nil)), nil
 }
 
+// DeriveID returns a stable key for the combination of issuer, subject and claim
+// type, so that tools can correlate the tokens issued for the same entity. It is
+// not a token ID: Encode always sets the ID (jti) to a hash of the claim
+// contents, so the derived key never becomes the ID of an encoded token. Store
+// it alongside the token instead.
+func DeriveID(issuer, subject, claimType string) string {
+	h := sha512.New512_256()
+	// separate the values so that different splits can't produce the same input
+	h.Write([]byte(issuer + "\x00" + subject + "\x00" + claimType))
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(h.Sum(nil))
+}
+
+// FindDuplicates groups the tokens by a fingerprint of their content, ignoring
+// the ID and issue time that change whenever a claim is signed again. The map
+// holds the sorted token IDs for each fingerprint, so duplicates share an entry.
+// Tokens that can't be decoded are skipped.
+func FindDuplicates(tokens []string) map[string][]string {
+	groups := make(map[string][]string)
+	for _, t := range tokens {
+		c, err := Decode(t)
+		if err != nil {
+			continue
+		}
+		fp, err := contentFingerprint(c)
+		if err != nil {
+			continue
+		}
+		groups[fp] = append(groups[fp], c.Claims().ID)
+	}
+	for _, ids := range groups {
+		sort.Strings(ids)
+	}
+	return groups
+}
+
+// contentFingerprint hashes the claim without its ID and issue time
+func contentFingerprint(c Claims) (string, error) {
+	cd := c.Claims()
+	id, iat := cd.ID, cd.IssuedAt
+	cd.ID, cd.IssuedAt = "", 0
+	j, err := json.Marshal(c)
+	cd.ID, cd.IssuedAt = id, iat
+	if err != nil {
+		return "", err
+	}
+	h := sha512.New512_256()
+	h.Write(j)
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(h.Sum(nil)), nil
+}
+
 // Encode encodes a claim into a JWT token. The claim is signed with the
 // provided nkey's private key
 func (c *ClaimsData) encode(kp nkeys.KeyPair, payload Claims) (string, error) {
@@ -250,6 +327,8 @@ func parseClaims(s string, target Claims) error {
 // the claims portion of the token and the public key in the claim.
 // Client code need to insure that the public key in the
 // claim is trusted.
+// The signature check is delegated to nkeys (ed25519.Verify), the
+// signature bytes are never compared directly.
 func (c *ClaimsData) verify(payload string, sig []byte) bool {
 	// decode the public key
 	kp, err := nkeys.FromPublicKey(c.Issuer)
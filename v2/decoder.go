@@ -51,6 +51,67 @@ type v1ClaimsDataDeletedFields struct {
 	IssuerAccount string    `json:"issuer_account,omitempty"`
 }
 
+// ErrTooManyPermissions is returned by DecodeWithOptions if a token
+// exceeds DecodeOptions.MaxPermissionEntries
+var ErrTooManyPermissions = errors.New("too many permission entries")
+
+// DecodeOptions are additional restrictions for DecodeWithOptions
+type DecodeOptions struct {
+	// MaxPermissionEntries limits the combined number of pub/sub allow/deny
+	// entries in a token, 0 means no limit
+	MaxPermissionEntries int
+}
+
+// DecodeWithOptions decodes a JWT string like Decode and
+// rejects the claim if it violates the options.
+func DecodeWithOptions(token string, opts DecodeOptions) (Claims, error) {
+	claim, err := Decode(token)
+	if err != nil {
+		return nil, err
+	}
+	if opts.MaxPermissionEntries > 0 && permissionEntries(claim) > opts.MaxPermissionEntries {
+		return nil, ErrTooManyPermissions
+	}
+	return claim, nil
+}
+
+func permissionEntries(claim Claims) int {
+	count := func(p *Permissions) int {
+		return len(p.Pub.Allow) + len(p.Pub.Deny) + len(p.Sub.Allow) + len(p.Sub.Deny)
+	}
+	switch c := claim.(type) {
+	case *UserClaims:
+		return count(&c.Permissions)
+	case *AccountClaims:
+		n := count(&c.DefaultPermissions)
+		for _, s := range c.SigningKeys {
+			switch us := s.(type) {
+			case *UserScope:
+				n += count(&us.Template.Permissions)
+			case UserScope:
+				n += count(&us.Template.Permissions)
+			}
+		}
+		return n
+	}
+	return 0
+}
+
+// SplitToken returns the base64 encoded header, payload and signature segments
+// of the token without decoding or verifying them
+func SplitToken(token string) (headerB64, payloadB64, sigB64 string, err error) {
+	chunks := strings.Split(token, ".")
+	if len(chunks) != 3 {
+		return "", "", "", errors.New("expected 3 chunks")
+	}
+	return chunks[0], chunks[1], chunks[2], nil
+}
+
+// DecodeSegment decodes a base64 encoded token segment as returned by SplitToken
+func DecodeSegment(seg string) ([]byte, error) {
+	return decodeString(seg)
+}
+
 // Decode takes a JWT string decodes it and validates it
 // and return the embedded Claims. If the token header
 // doesn't match the expected algorithm, or the claim is
@@ -123,6 +184,39 @@ func Decode(token string) (Claims, error) {
 	return claim, nil
 }
 
+// ErrClaimTypeMismatch is returned when a claim contains fields of a different claim type
+var ErrClaimTypeMismatch = errors.New("claim contents don't match the claim type")
+
+// foreignClaimFields lists, by claim type, the fields of other claim types that
+// are not valid in the nats section of a claim
+var foreignClaimFields = map[string][]string{
+	OperatorClaim:   {"imports", "exports", "pub", "sub", "issuer_account"},
+	AccountClaim:    {"pub", "sub", "resp", "bearer_token", "issuer_account", "system_account", "account_server_url"},
+	UserClaim:       {"imports", "exports", "signing_keys", "default_permissions", "mappings", "revocations", "system_account"},
+	ActivationClaim: {"imports", "exports", "pub", "sub", "signing_keys"},
+}
+
+// checkClaimShape returns ErrClaimTypeMismatch if the nats section of the claim
+// contains fields that belong to a different claim type
+func checkClaimShape(kind string, data []byte) error {
+	fields, ok := foreignClaimFields[kind]
+	if !ok {
+		return nil
+	}
+	var shape struct {
+		Nats map[string]json.RawMessage `json:"nats"`
+	}
+	if err := json.Unmarshal(data, &shape); err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if _, ok := shape.Nats[f]; ok {
+			return fmt.Errorf("%w: %s claim contains %q", ErrClaimTypeMismatch, kind, f)
+		}
+	}
+	return nil
+}
+
 func loadClaims(data []byte) (int, Claims, error) {
 	var id identifier
 	if err := json.Unmarshal(data, &id); err != nil {
@@ -133,6 +227,12 @@ func loadClaims(data []byte) (int, Claims, error) {
 		return -1, nil, errors.New("JWT was generated by a newer version ")
 	}
 
+	if id.Version() > 1 {
+		if err := checkClaimShape(string(id.Kind()), data); err != nil {
+			return -1, nil, err
+		}
+	}
+
 	var claim Claims
 	var err error
 	switch id.Kind() {
@@ -148,6 +248,8 @@ func loadClaims(data []byte) (int, Claims, error) {
 		claim, err = loadAuthorizationRequest(data, id.Version())
 	case AuthorizationResponseClaim:
 		claim, err = loadAuthorizationResponse(data, id.Version())
+	case AccountListClaim:
+		claim, err = loadAccountList(data, id.Version())
 	case "cluster":
 		return -1, nil, errors.New("ClusterClaims are not supported")
 	case "server":
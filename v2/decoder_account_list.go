@@ -0,0 +1,32 @@
+/*
+ * Copyright 2023 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwt
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func loadAccountList(data []byte, version int) (*AccountListClaims, error) {
+	if version < 2 {
+		return nil, fmt.Errorf("account lists require version 2 - received %d", version)
+	}
+	var al AccountListClaims
+	if err := json.Unmarshal(data, &al); err != nil {
+		return nil, err
+	}
+	return &al, nil
+}
@@ -17,6 +17,7 @@ package jwt
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
 	"strings"
@@ -399,3 +400,197 @@ func TestUsingURLDecoder(t *testing.T) {
 		t.Fatal("should have returned activation")
 	}
 }
+
+func TestDecodeWithOptionsMaxPermissionEntries(t *testing.T) {
+	akp := createAccountNKey(t)
+	uc := NewUserClaims(publicKey(createUserNKey(t), t))
+	uc.Pub.Allow.Add("a", "b")
+	uc.Pub.Deny.Add("c")
+	uc.Sub.Allow.Add("d")
+	uc.Sub.Deny.Add("e")
+	token := encode(uc, akp, t)
+
+	_, err := DecodeWithOptions(token, DecodeOptions{MaxPermissionEntries: 5})
+	AssertNoError(err, t)
+	_, err = DecodeWithOptions(token, DecodeOptions{})
+	AssertNoError(err, t)
+	_, err = DecodeWithOptions(token, DecodeOptions{MaxPermissionEntries: 4})
+	AssertEquals(ErrTooManyPermissions, err, t)
+
+	// account default permissions and scoped signing key templates add up
+	ac := NewAccountClaims(publicKey(akp, t))
+	ac.DefaultPermissions.Pub.Allow.Add("a", "b")
+	scope := NewUserScope()
+	scope.Key = publicKey(createAccountNKey(t), t)
+	scope.Template.Sub.Allow.Add("c", "d")
+	ac.SigningKeys.AddScopedSigner(scope)
+	token = encode(ac, createOperatorNKey(t), t)
+
+	_, err = DecodeWithOptions(token, DecodeOptions{MaxPermissionEntries: 4})
+	AssertNoError(err, t)
+	_, err = DecodeWithOptions(token, DecodeOptions{MaxPermissionEntries: 3})
+	AssertEquals(ErrTooManyPermissions, err, t)
+}
+
+func TestSplitToken(t *testing.T) {
+	akp := createAccountNKey(t)
+	uc := NewUserClaims(publicKey(createUserNKey(t), t))
+	token := encode(uc, akp, t)
+
+	h, p, s, err := SplitToken(token)
+	AssertNoError(err, t)
+	AssertEquals(token, h+"."+p+"."+s, t)
+
+	hd, err := DecodeSegment(h)
+	AssertNoError(err, t)
+	var header Header
+	AssertNoError(json.Unmarshal(hd, &header), t)
+	AssertEquals(AlgorithmNkey, header.Algorithm, t)
+
+	pd, err := DecodeSegment(p)
+	AssertNoError(err, t)
+	AssertTrue(strings.Contains(string(pd), uc.Subject), t)
+
+	sig, err := DecodeSegment(s)
+	AssertNoError(err, t)
+	AssertEquals(64, len(sig), t)
+
+	_, err = DecodeSegment("not base64!")
+	AssertTrue(err != nil, t)
+
+	for _, bad := range []string{"", "a.b", "a.b.c.d", h + "." + p} {
+		_, _, _, err = SplitToken(bad)
+		AssertTrue(err != nil, t)
+	}
+}
+
+func TestDecodeClaimTypeMismatch(t *testing.T) {
+	akp := createAccountNKey(t)
+	uc := NewUserClaims(publicKey(createUserNKey(t), t))
+	token := encode(uc, akp, t)
+	h, p, _, err := SplitToken(token)
+	AssertNoError(err, t)
+
+	// add an imports field to the user claim and resign it
+	payload, err := DecodeSegment(p)
+	AssertNoError(err, t)
+	var m map[string]interface{}
+	AssertNoError(json.Unmarshal(payload, &m), t)
+	m["nats"].(map[string]interface{})["imports"] = []interface{}{map[string]interface{}{"subject": "foo"}}
+	payload, err = json.Marshal(m)
+	AssertNoError(err, t)
+	p = encodeToString(payload)
+	sig, err := akp.Sign([]byte(h + "." + p))
+	AssertNoError(err, t)
+	tampered := h + "." + p + "." + encodeToString(sig)
+
+	_, err = Decode(tampered)
+	AssertTrue(errors.Is(err, ErrClaimTypeMismatch), t)
+	_, err = DecodeUserClaims(tampered)
+	AssertTrue(errors.Is(err, ErrClaimTypeMismatch), t)
+
+	// regular claims of all types still decode
+	_, err = DecodeUserClaims(token)
+	AssertNoError(err, t)
+	ac := NewAccountClaims(publicKey(akp, t))
+	ac.Imports.Add(&Import{Subject: "foo", Account: publicKey(createAccountNKey(t), t), Type: Stream})
+	ac.DefaultPermissions.Pub.Allow.Add("foo")
+	_, err = DecodeAccountClaims(encode(ac, createOperatorNKey(t), t))
+	AssertNoError(err, t)
+}
+
+// v1Token signs the payload like a v1 token, over the encoded payload only
+func v1Token(t *testing.T, kp nkeys.KeyPair, payload string) string {
+	h, err := json.Marshal(Header{TokenTypeJwt, AlgorithmNkeyOld})
+	AssertNoError(err, t)
+	p := encodeToString([]byte(payload))
+	sig, err := kp.Sign([]byte(p))
+	AssertNoError(err, t)
+	return fmt.Sprintf("%s.%s.%s", encodeToString(h), p, encodeToString(sig))
+}
+
+func TestDecodeGenericV1WithoutData(t *testing.T) {
+	akp := createAccountNKey(t)
+	token := v1Token(t, akp, fmt.Sprintf(`{"iss":%q,"sub":%q,"type":"user"}`, publicKey(akp, t), publicKey(createUserNKey(t), t)))
+	gc, err := DecodeGenericSafe(token)
+	AssertNoError(err, t)
+	AssertEquals("user", gc.Data["type"], t)
+}
+
+func malformedTokens(tb testing.TB) []string {
+	akp, err := nkeys.CreateAccount()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	ukp, err := nkeys.CreateUser()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	upk, err := ukp.PublicKey()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	token, err := NewUserClaims(upk).Encode(akp)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	h, p, sig, err := SplitToken(token)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	header := encodeToString([]byte(`{"typ":"JWT","alg":"ed25519-nkey"}`))
+	return []string{
+		token,
+		"",
+		".",
+		"..",
+		"...",
+		"a.b.c",
+		h,
+		h + "." + p,
+		h + "." + p + ".",
+		h + "." + p[:len(p)/2] + "." + sig,
+		h + "." + p + "." + sig[:len(sig)/2],
+		h[:len(h)-1] + "." + p + "." + sig,
+		"=hello=." + p + "." + sig,
+		h + ".=hello=." + sig,
+		h + "." + p + ".=hello=",
+		h + "." + p + "." + sig + "." + sig,
+		header + "." + encodeToString([]byte("null")) + "." + sig,
+		header + "." + encodeToString([]byte("[]")) + "." + sig,
+		header + "." + encodeToString([]byte(`{"nats":null}`)) + "." + sig,
+		header + "." + encodeToString([]byte(`{"nats":[1,2]}`)) + "." + sig,
+		header + "." + encodeToString([]byte(`{"sub":"\xff\xfe","iss":"\u0000"}`)) + "." + sig,
+		header + "." + encodeToString([]byte("{\"sub\":\"\xff\xfe\"}")) + "." + sig,
+		header + "." + encodeToString([]byte(`{"exp":1e400}`)) + "." + sig,
+		header + "." + encodeToString([]byte(strings.Repeat("[", 100000))) + "." + sig,
+		header + "." + strings.Repeat("A", 1<<20) + "." + sig,
+		encodeToString([]byte(`{"typ":"JWT","alg":"ed25519"}`)) + "." + p + "." + sig,
+		encodeToString([]byte(`{"typ":"JWT"}`)) + "." + p + "." + sig,
+		encodeToString([]byte("\xff\xfe")) + "." + p + "." + sig,
+	}
+}
+
+func TestDecodeMalformedTokens(t *testing.T) {
+	for i, token := range malformedTokens(t)[1:] {
+		if _, err := DecodeGenericSafe(token); err == nil {
+			t.Fatalf("expected malformed token %d to fail to decode", i+1)
+		}
+		if _, err := Decode(token); err == nil {
+			t.Fatalf("expected malformed token %d to fail to decode", i+1)
+		}
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	for _, token := range malformedTokens(f) {
+		f.Add(token)
+	}
+	f.Fuzz(func(t *testing.T, token string) {
+		if gc, err := DecodeGenericSafe(token); (gc == nil) == (err == nil) {
+			t.Fatalf("expected either claims or an error, got %v and %v", gc, err)
+		}
+		// Decode shares the decode path and must not panic either
+		Decode(token)
+	})
+}
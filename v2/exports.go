@@ -16,10 +16,14 @@
 package jwt
 
 import (
+	"crypto/sha512"
+	"encoding/base32"
 	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/nats-io/nkeys"
 )
 
 // ResponseType is used to store an export response type
@@ -36,6 +40,17 @@ const (
 	ResponseTypeChunked = "Chunked"
 )
 
+// Cardinality is used to store how many accounts may import an export
+type Cardinality string
+
+const (
+	// CardinalityOne is used for an export dedicated to a single importing account
+	CardinalityOne Cardinality = "One"
+
+	// CardinalityMany is used for an export shared by any number of importing accounts, it is the default
+	CardinalityMany Cardinality = "Many"
+)
+
 // ServiceLatency is used when observing and exported service for
 // latency measurements.
 // Sampling 1-100, represents sampling rate, defaults to 100.
@@ -119,6 +134,11 @@ type Export struct {
 	Latency              *ServiceLatency `json:"service_latency,omitempty"`
 	AccountTokenPosition uint            `json:"account_token_position,omitempty"`
 	Advertise            bool            `json:"advertise,omitempty"`
+	LatencyBudgetMs      int64           `json:"latency_budget_ms,omitempty"`
+	ContractVersion      int             `json:"contract_version,omitempty"`
+	MaxImporters         int             `json:"max_importers,omitempty"`
+	GrantedImporters     StringList      `json:"granted_importers,omitempty"`
+	Cardinality          Cardinality     `json:"cardinality,omitempty"`
 	Info
 }
 
@@ -148,6 +168,50 @@ func (e *Export) IsStreamResponse() bool {
 	return e.Type == Service && e.ResponseType == ResponseTypeStream
 }
 
+// WithinBudget returns true if the observed latency in milliseconds doesn't exceed
+// the latency budget of the export. Exports without a budget are always within budget.
+func (e *Export) WithinBudget(observedMs int64) bool {
+	return e.LatencyBudgetMs <= 0 || observedMs <= e.LatencyBudgetMs
+}
+
+// GenerateLatencyResults returns a latency results subject of the form
+// $SYS.LATENCY.<hash>, derived from the account and the export subject.
+// If latency tracking is configured, it also becomes the results subject.
+func (e *Export) GenerateLatencyResults(accountPub string) string {
+	h := sha512.New512_256()
+	h.Write([]byte(accountPub + "\x00" + string(e.Subject)))
+	subj := "$SYS.LATENCY." + base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(h.Sum(nil))
+	if e.Latency != nil {
+		e.Latency.Results = Subject(subj)
+	}
+	return subj
+}
+
+// IsCompatibleWith returns true if an importer built against the contract version
+// can use the export. Any change of the version is considered breaking.
+// Exports or importers without a version are always compatible.
+func (e *Export) IsCompatibleWith(importerExpectedVersion int) bool {
+	return e.ContractVersion == 0 || importerExpectedVersion == 0 || e.ContractVersion == importerExpectedVersion
+}
+
+// Grant records that the account was granted an import of the export.
+// Granting an account again has no effect. An error is returned if the key is
+// not an account key or if MaxImporters accounts were granted already.
+// A MaxImporters of 0 means there is no limit.
+func (e *Export) Grant(accountPub string) error {
+	if !nkeys.IsValidPublicAccountKey(accountPub) {
+		return fmt.Errorf("%q is not an account public key", accountPub)
+	}
+	if e.GrantedImporters.Contains(accountPub) {
+		return nil
+	}
+	if e.MaxImporters > 0 && len(e.GrantedImporters) >= e.MaxImporters {
+		return fmt.Errorf("export %q already granted to the maximum of %d importers", e.Subject, e.MaxImporters)
+	}
+	e.GrantedImporters.Add(accountPub)
+	return nil
+}
+
 // Validate appends validation issues to the passed in results list
 func (e *Export) Validate(vr *ValidationResults) {
 	if e == nil {
@@ -175,6 +239,29 @@ func (e *Export) Validate(vr *ValidationResults) {
 	if e.ResponseThreshold.Nanoseconds() > 0 && !e.IsService() {
 		vr.AddError("response threshold only valid for services")
 	}
+	if e.ContractVersion < 0 {
+		vr.AddError("negative contract version is invalid")
+	}
+	if e.Cardinality != "" && e.Cardinality != CardinalityOne && e.Cardinality != CardinalityMany {
+		vr.AddError("invalid export cardinality: %q", e.Cardinality)
+	}
+	if e.MaxImporters < 0 {
+		vr.AddError("negative max importers is invalid")
+	}
+	if e.MaxImporters > 0 && len(e.GrantedImporters) > e.MaxImporters {
+		vr.AddError("export granted to %d importers, more than the maximum of %d", len(e.GrantedImporters), e.MaxImporters)
+	}
+	for _, a := range e.GrantedImporters {
+		if !nkeys.IsValidPublicAccountKey(a) {
+			vr.AddError("granted importer %q is not an account public key", a)
+		}
+	}
+	if e.LatencyBudgetMs < 0 {
+		vr.AddError("negative latency budget is invalid")
+	}
+	if e.LatencyBudgetMs > 0 && !e.IsService() {
+		vr.AddError("latency budget only valid for services")
+	}
 	e.Subject.Validate(vr)
 	if e.AccountTokenPosition > 0 {
 		if !e.Subject.HasWildCards() {
@@ -300,6 +387,43 @@ func (e *Exports) HasExportContainingSubject(subject Subject) bool {
 	return false
 }
 
+// GroupByNamespace groups the exports by the first token of their subject.
+// Exports whose subject starts with a wildcard are grouped under "*".
+func (e Exports) GroupByNamespace() map[string]Exports {
+	groups := make(map[string]Exports)
+	for _, v := range e {
+		if v == nil {
+			continue
+		}
+		ns := strings.SplitN(string(v.Subject), ".", 2)[0]
+		if ns == "*" || ns == ">" {
+			ns = "*"
+		}
+		groups[ns] = append(groups[ns], v)
+	}
+	return groups
+}
+
+// CoverageGap returns the parts of the import subject that no export covers,
+// or nil if an export covers all of it. Where exports name tokens explicitly the
+// gap is expanded for them, a remaining * or > stands for the tokens no export
+// names. The result is best effort and sorted.
+func CoverageGap(importSubj string, exports Exports) []string {
+	gap, err := NewSubjectSet(importSubj)
+	if err != nil {
+		return []string{importSubj}
+	}
+	for _, e := range exports {
+		if e == nil {
+			continue
+		}
+		if es, err := NewSubjectSet(string(e.Subject)); err == nil {
+			gap = gap.Subtract(es)
+		}
+	}
+	return gap.Patterns()
+}
+
 func (e Exports) Len() int {
 	return len(e)
 }
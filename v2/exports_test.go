@@ -17,6 +17,7 @@ package jwt
 
 import (
 	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -440,3 +441,154 @@ func TestExport_ResponseThreshold(t *testing.T) {
 		t.Fatal("expected this to fail due to negative duration")
 	}
 }
+
+func TestExport_GroupByNamespace(t *testing.T) {
+	var exports Exports
+	exports.Add(&Export{Subject: "orders.*", Type: Stream},
+		&Export{Subject: "orders.new", Type: Service},
+		&Export{Subject: "billing.*", Type: Stream},
+		&Export{Subject: "*.audit", Type: Stream},
+		&Export{Subject: ">", Type: Service})
+
+	groups := exports.GroupByNamespace()
+	AssertEquals(3, len(groups), t)
+	AssertEquals(2, len(groups["orders"]), t)
+	AssertEquals(Subject("orders.*"), groups["orders"][0].Subject, t)
+	AssertEquals(Subject("orders.new"), groups["orders"][1].Subject, t)
+	AssertEquals(1, len(groups["billing"]), t)
+	AssertEquals(2, len(groups["*"]), t)
+}
+
+func TestExport_LatencyBudget(t *testing.T) {
+	stream := &Export{Subject: "foo", Type: Stream, LatencyBudgetMs: 10}
+	vr := CreateValidationResults()
+	stream.Validate(vr)
+	if vr.IsEmpty() {
+		t.Fatal("expected this to fail due to latency budget on a stream")
+	}
+
+	negative := &Export{Subject: "foo", Type: Service, LatencyBudgetMs: -1}
+	vr = CreateValidationResults()
+	negative.Validate(vr)
+	if vr.IsEmpty() {
+		t.Fatal("expected this to fail due to negative latency budget")
+	}
+
+	service := &Export{Subject: "foo", Type: Service, LatencyBudgetMs: 50}
+	vr = CreateValidationResults()
+	service.Validate(vr)
+	if !vr.IsEmpty() {
+		t.Fatal("expected latency budget to be valid for a service")
+	}
+	AssertTrue(service.WithinBudget(49), t)
+	AssertTrue(service.WithinBudget(50), t)
+	AssertFalse(service.WithinBudget(51), t)
+
+	// no budget, nothing exceeds it
+	AssertTrue((&Export{Subject: "foo", Type: Service}).WithinBudget(1000), t)
+}
+
+func TestExport_ContractVersion(t *testing.T) {
+	e := &Export{Subject: "foo", Type: Service, ContractVersion: 2}
+	i := &Import{Subject: "foo", Type: Service, ExpectedContractVersion: 1}
+	AssertFalse(e.IsCompatibleWith(i.ExpectedContractVersion), t)
+	AssertTrue(e.IsCompatibleWith(2), t)
+	AssertTrue(e.IsCompatibleWith(0), t)
+	AssertTrue((&Export{Subject: "foo", Type: Service}).IsCompatibleWith(1), t)
+
+	vr := CreateValidationResults()
+	e.ContractVersion = -1
+	e.Validate(vr)
+	if vr.IsEmpty() {
+		t.Fatal("expected this to fail due to negative contract version")
+	}
+}
+
+func TestExport_Grant(t *testing.T) {
+	e := &Export{Subject: "foo", Type: Service, MaxImporters: 2}
+	a1, a2, a3 := publicKey(createAccountNKey(t), t), publicKey(createAccountNKey(t), t), publicKey(createAccountNKey(t), t)
+	AssertNoError(e.Grant(a1), t)
+	AssertNoError(e.Grant(a2), t)
+	// granting again doesn't count against the limit
+	AssertNoError(e.Grant(a1), t)
+	AssertEquals(2, len(e.GrantedImporters), t)
+	if err := e.Grant(a3); err == nil {
+		t.Fatal("expected grant beyond max importers to fail")
+	}
+	AssertFalse(e.GrantedImporters.Contains(a3), t)
+	if err := e.Grant(publicKey(createUserNKey(t), t)); err == nil {
+		t.Fatal("expected grant to a user to fail")
+	}
+
+	vr := CreateValidationResults()
+	e.Validate(vr)
+	AssertTrue(vr.IsEmpty(), t)
+
+	e.GrantedImporters.Add(a3)
+	vr = CreateValidationResults()
+	e.Validate(vr)
+	if vr.IsEmpty() {
+		t.Fatal("expected this to fail due to too many granted importers")
+	}
+
+	unlimited := &Export{Subject: "foo", Type: Service}
+	AssertNoError(unlimited.Grant(a1), t)
+	AssertNoError(unlimited.Grant(a2), t)
+	AssertNoError(unlimited.Grant(a3), t)
+	unlimited.GrantedImporters.Add("bad")
+	vr = CreateValidationResults()
+	unlimited.Validate(vr)
+	if vr.IsEmpty() {
+		t.Fatal("expected this to fail due to a bad importer key")
+	}
+
+	vr = CreateValidationResults()
+	(&Export{Subject: "foo", Type: Service, MaxImporters: -1}).Validate(vr)
+	if vr.IsEmpty() {
+		t.Fatal("expected this to fail due to negative max importers")
+	}
+}
+
+func TestExport_GenerateLatencyResults(t *testing.T) {
+	apk := publicKey(createAccountNKey(t), t)
+	e1 := &Export{Subject: "orders", Type: Service, Latency: &ServiceLatency{Sampling: 100}}
+	e2 := &Export{Subject: "billing", Type: Service}
+
+	r1 := e1.GenerateLatencyResults(apk)
+	r2 := e2.GenerateLatencyResults(apk)
+	AssertTrue(strings.HasPrefix(r1, "$SYS.LATENCY."), t)
+	AssertTrue(r1 != r2, t)
+	AssertEquals(r1, e1.GenerateLatencyResults(apk), t)
+	AssertTrue(r1 != e1.GenerateLatencyResults(publicKey(createAccountNKey(t), t)), t)
+	AssertEquals(Subject(e1.GenerateLatencyResults(apk)), e1.Latency.Results, t)
+	AssertTrue(e2.Latency == nil, t)
+
+	vr := CreateValidationResults()
+	e1.Validate(vr)
+	AssertTrue(vr.IsEmpty(), t)
+}
+
+func TestCoverageGap(t *testing.T) {
+	var exports Exports
+	exports.Add(&Export{Subject: "orders.new", Type: Stream}, &Export{Subject: "orders.cancel", Type: Stream})
+
+	// other single tokens and everything below, including below orders.new, is missing
+	gap := CoverageGap("orders.>", exports)
+	AssertEquals(2, len(gap), t)
+	AssertEquals("orders.*", gap[0], t)
+	AssertEquals("orders.*.>", gap[1], t)
+
+	AssertEquals(0, len(CoverageGap("orders.new", exports)), t)
+	gap = CoverageGap("billing.>", exports)
+	AssertEquals(1, len(gap), t)
+	AssertEquals("billing.>", gap[0], t)
+
+	exports = Exports{}
+	exports.Add(&Export{Subject: "orders.*", Type: Stream}, &Export{Subject: "orders.new.>", Type: Stream})
+	gap = CoverageGap("orders.>", exports)
+	AssertEquals(1, len(gap), t)
+	AssertEquals("orders.*.>", gap[0], t)
+
+	exports.Add(&Export{Subject: "orders.>", Type: Stream})
+	AssertEquals(0, len(CoverageGap("orders.>", exports)), t)
+}
@@ -0,0 +1,303 @@
+/*
+ * Copyright 2023 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwt
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nkeys"
+)
+
+// FleetStats aggregates import/export statistics across many accounts
+type FleetStats struct {
+	Accounts            int     `json:"accounts"`
+	Imports             int     `json:"imports"`
+	Exports             int     `json:"exports"`
+	Unresolved          int     `json:"unresolved"`
+	MostImportedSubject Subject `json:"most_imported_subject,omitempty"`
+	MostImportedCount   int     `json:"most_imported_count,omitempty"`
+}
+
+// CollectFleetStats computes FleetStats for accounts keyed by their public key.
+// An import is unresolved if the account it references is not in the map or
+// doesn't have an export matching the import.
+func CollectFleetStats(accounts map[string]*Account) *FleetStats {
+	fs := &FleetStats{Accounts: len(accounts)}
+	counts := make(map[*Export]int)
+	for _, a := range accounts {
+		if a == nil {
+			continue
+		}
+		fs.Exports += len(a.Exports)
+		for _, i := range a.Imports {
+			if i == nil {
+				continue
+			}
+			fs.Imports++
+			e := resolveImport(accounts, i)
+			if e == nil {
+				fs.Unresolved++
+				continue
+			}
+			counts[e]++
+		}
+	}
+	for e, c := range counts {
+		if c > fs.MostImportedCount || (c == fs.MostImportedCount && e.Subject < fs.MostImportedSubject) {
+			fs.MostImportedSubject = e.Subject
+			fs.MostImportedCount = c
+		}
+	}
+	return fs
+}
+
+// CountDependents returns the sorted public keys of the accounts in the map whose
+// imports resolve to the export of exporter with the subject exportSubj. The
+// exporter has to be one of the accounts in the map.
+func CountDependents(exporter *Account, exportSubj string, accounts map[string]*Account) []string {
+	var export *Export
+	for _, e := range exporter.Exports {
+		if e != nil && string(e.Subject) == exportSubj {
+			export = e
+			break
+		}
+	}
+	if export == nil {
+		return nil
+	}
+	var dependents []string
+	for pk, a := range accounts {
+		if a == nil || a == exporter {
+			continue
+		}
+		for _, i := range a.Imports {
+			if i != nil && accounts[i.Account] == exporter && resolveImport(accounts, i) == export {
+				dependents = append(dependents, pk)
+				break
+			}
+		}
+	}
+	sort.Strings(dependents)
+	return dependents
+}
+
+// EnforceCardinality returns an error if more than one of the accounts in the
+// map imports the export of exporter with the subject exportSubj while its
+// cardinality is CardinalityOne. Like CountDependents, the exporter has to be
+// one of the accounts in the map.
+func EnforceCardinality(exporter *Account, exportSubj string, accounts map[string]*Account) error {
+	var export *Export
+	for _, e := range exporter.Exports {
+		if e != nil && string(e.Subject) == exportSubj {
+			export = e
+			break
+		}
+	}
+	if export == nil {
+		return fmt.Errorf("account has no export %q", exportSubj)
+	}
+	if export.Cardinality != CardinalityOne {
+		return nil
+	}
+	if deps := CountDependents(exporter, exportSubj, accounts); len(deps) > 1 {
+		return fmt.Errorf("export %q is limited to one importer, imported by %d accounts", exportSubj, len(deps))
+	}
+	return nil
+}
+
+// OrphanedExports returns, keyed by account public key, the sorted subjects of
+// the exports no other account in the map imports. Accounts without orphaned
+// exports are not included.
+func OrphanedExports(accounts map[string]*Account) map[string][]string {
+	orphaned := make(map[string][]string)
+	for pk, a := range accounts {
+		if a == nil {
+			continue
+		}
+		for _, e := range a.Exports {
+			if e != nil && len(CountDependents(a, string(e.Subject), accounts)) == 0 {
+				orphaned[pk] = append(orphaned[pk], string(e.Subject))
+			}
+		}
+		sort.Strings(orphaned[pk])
+	}
+	for pk, subjects := range orphaned {
+		if len(subjects) == 0 {
+			delete(orphaned, pk)
+		}
+	}
+	return orphaned
+}
+
+// ValidateAccounts decodes and validates the account tokens using the specified
+// number of workers. The returned map holds the errors keyed like the tokens,
+// valid accounts are not included. If a key is an account public key the token
+// subject has to match it. If set, progress is called after each token.
+func ValidateAccounts(tokens map[string]string, workers int, progress func(done, total int)) map[string]error {
+	if workers < 1 {
+		workers = 1
+	}
+	keys := make(chan string)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	errs := make(map[string]error)
+	done := 0
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := range keys {
+				err := validateAccount(k, tokens[k])
+				mu.Lock()
+				if err != nil {
+					errs[k] = err
+				}
+				done++
+				if progress != nil {
+					progress(done, len(tokens))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for k := range tokens {
+		keys <- k
+	}
+	close(keys)
+	wg.Wait()
+	return errs
+}
+
+func validateAccount(key string, token string) error {
+	ac, err := DecodeAccountClaims(token)
+	if err != nil {
+		return err
+	}
+	if nkeys.IsValidPublicAccountKey(key) && ac.Subject != key {
+		return fmt.Errorf("account token subject %q doesn't match %q", ac.Subject, key)
+	}
+	vr := CreateValidationResults()
+	ac.Validate(vr)
+	for _, i := range vr.Issues {
+		if i.Blocking || i.TimeCheck {
+			return errors.New(i.Description)
+		}
+	}
+	return nil
+}
+
+// ReserveExport records accountPub as the owner of the export subject in the
+// registry, which maps subjects to their owning account. An error is returned if
+// the subject overlaps a subject owned by a different account.
+func ReserveExport(registry map[string]string, accountPub, subject string) error {
+	set, err := NewSubjectSet(subject)
+	if err != nil {
+		return err
+	}
+	for owned, owner := range registry {
+		if owner == accountPub {
+			continue
+		}
+		if taken, err := NewSubjectSet(owned); err == nil && set.Overlaps(taken) {
+			return fmt.Errorf("export subject %q overlaps %q owned by %q", subject, owned, owner)
+		}
+	}
+	registry[subject] = accountPub
+	return nil
+}
+
+// UnresolvedReason describes why an import can't be resolved
+type UnresolvedReason string
+
+const (
+	// UnresolvedBadKey is used for imports that don't reference an account public key
+	UnresolvedBadKey UnresolvedReason = "bad_key"
+	// UnresolvedUnknownAccount is used for imports from accounts that are not known
+	UnresolvedUnknownAccount UnresolvedReason = "unknown_account"
+	// UnresolvedNoExport is used for imports the exporting account has no export for
+	UnresolvedNoExport UnresolvedReason = "no_export"
+	// UnresolvedMissingActivation is used for imports without a valid activation token
+	// for an export requiring one
+	UnresolvedMissingActivation UnresolvedReason = "missing_activation"
+	// UnresolvedExpiredActivation is used for imports with an expired activation token
+	UnresolvedExpiredActivation UnresolvedReason = "expired_activation"
+	// UnresolvedExpiredImport is used for imports past their ExpiresAt
+	UnresolvedExpiredImport UnresolvedReason = "expired_import"
+)
+
+// UnresolvedImport describes an import that can't be resolved
+type UnresolvedImport struct {
+	Index     int              `json:"index"`
+	Reference string           `json:"account"`
+	Subject   Subject          `json:"subject"`
+	Reason    UnresolvedReason `json:"reason"`
+}
+
+// UnresolvedImports returns the imports of the account that can't be resolved.
+// Exporting accounts are looked up by public key in accounts, if accounts is nil
+// only the import and its activation token are checked.
+func (a *Account) UnresolvedImports(accounts map[string]*Account) []UnresolvedImport {
+	var unresolved []UnresolvedImport
+	now := time.Now().Unix()
+	for idx, i := range a.Imports {
+		if i == nil {
+			continue
+		}
+		var reason UnresolvedReason
+		if !nkeys.IsValidPublicAccountKey(i.Account) {
+			reason = UnresolvedBadKey
+		} else if !i.IsActive(time.Unix(now, 0)) {
+			reason = UnresolvedExpiredImport
+		} else if i.Token != "" {
+			if act, err := DecodeActivationClaims(i.Token); err != nil {
+				reason = UnresolvedMissingActivation
+			} else if act.Expires > 0 && act.Expires < now {
+				reason = UnresolvedExpiredActivation
+			}
+		}
+		if reason == "" && accounts != nil {
+			if exporter, ok := accounts[i.Account]; !ok || exporter == nil {
+				reason = UnresolvedUnknownAccount
+			} else if e := resolveImport(accounts, i); e == nil {
+				reason = UnresolvedNoExport
+			} else if e.TokenReq && i.Token == "" {
+				reason = UnresolvedMissingActivation
+			}
+		}
+		if reason != "" {
+			unresolved = append(unresolved, UnresolvedImport{Index: idx, Reference: i.Account, Subject: i.Subject, Reason: reason})
+		}
+	}
+	return unresolved
+}
+
+// resolveImport returns the export of the referenced account matching the import
+func resolveImport(accounts map[string]*Account, i *Import) *Export {
+	exporter, ok := accounts[i.Account]
+	if !ok || exporter == nil {
+		return nil
+	}
+	for _, e := range exporter.Exports {
+		if e != nil && e.Type == i.Type && patternCovers(string(e.Subject), string(i.Subject)) {
+			return e
+		}
+	}
+	return nil
+}
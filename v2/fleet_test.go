@@ -0,0 +1,280 @@
+/*
+ * Copyright 2023 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwt
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCollectFleetStats(t *testing.T) {
+	apk1 := publicKey(createAccountNKey(t), t)
+	apk2 := publicKey(createAccountNKey(t), t)
+	apk3 := publicKey(createAccountNKey(t), t)
+
+	a1 := &Account{}
+	a1.Exports.Add(&Export{Subject: "orders.>", Type: Stream}, &Export{Subject: "billing", Type: Service})
+	a2 := &Account{}
+	a2.Exports.Add(&Export{Subject: "audit", Type: Stream})
+	a2.Imports.Add(&Import{Subject: "orders.new", Account: apk1, Type: Stream},
+		&Import{Subject: "billing", Account: apk1, Type: Service})
+	a3 := &Account{}
+	a3.Imports.Add(&Import{Subject: "orders.>", Account: apk1, Type: Stream},
+		&Import{Subject: "audit", Account: apk2, Type: Stream},
+		// wrong type
+		&Import{Subject: "audit", Account: apk2, Type: Service},
+		// unknown account
+		&Import{Subject: "foo", Account: publicKey(createAccountNKey(t), t), Type: Stream})
+
+	fs := CollectFleetStats(map[string]*Account{apk1: a1, apk2: a2, apk3: a3})
+	AssertEquals(3, fs.Accounts, t)
+	AssertEquals(6, fs.Imports, t)
+	AssertEquals(3, fs.Exports, t)
+	AssertEquals(2, fs.Unresolved, t)
+	AssertEquals(Subject("orders.>"), fs.MostImportedSubject, t)
+	AssertEquals(2, fs.MostImportedCount, t)
+
+	d, err := json.Marshal(fs)
+	AssertNoError(err, t)
+	var fs2 FleetStats
+	AssertNoError(json.Unmarshal(d, &fs2), t)
+	AssertEquals(*fs, fs2, t)
+}
+
+func TestCountDependents(t *testing.T) {
+	apk1 := publicKey(createAccountNKey(t), t)
+	apk2 := publicKey(createAccountNKey(t), t)
+	apk3 := publicKey(createAccountNKey(t), t)
+	apk4 := publicKey(createAccountNKey(t), t)
+
+	a1 := &Account{}
+	a1.Exports.Add(&Export{Subject: "orders.>", Type: Stream}, &Export{Subject: "billing", Type: Service})
+	a2 := &Account{}
+	a2.Imports.Add(&Import{Subject: "orders.new", Account: apk1, Type: Stream})
+	a3 := &Account{}
+	a3.Imports.Add(&Import{Subject: "orders.>", Account: apk1, Type: Stream})
+	a4 := &Account{}
+	a4.Imports.Add(&Import{Subject: "billing", Account: apk1, Type: Service})
+	accounts := map[string]*Account{apk1: a1, apk2: a2, apk3: a3, apk4: a4}
+
+	deps := CountDependents(a1, "orders.>", accounts)
+	AssertEquals(2, len(deps), t)
+	for _, pk := range []string{apk2, apk3} {
+		AssertTrue(pk == deps[0] || pk == deps[1], t)
+	}
+	AssertTrue(deps[0] < deps[1], t)
+
+	deps = CountDependents(a1, "billing", accounts)
+	AssertEquals(1, len(deps), t)
+	AssertEquals(apk4, deps[0], t)
+
+	AssertEquals(0, len(CountDependents(a1, "unknown", accounts)), t)
+}
+
+func TestEnforceCardinality(t *testing.T) {
+	epk := publicKey(createAccountNKey(t), t)
+	apk1 := publicKey(createAccountNKey(t), t)
+	apk2 := publicKey(createAccountNKey(t), t)
+	apk3 := publicKey(createAccountNKey(t), t)
+
+	exporter := &Account{}
+	exporter.Exports.Add(&Export{Subject: "dedicated", Type: Service, Cardinality: CardinalityOne},
+		&Export{Subject: "shared", Type: Service, Cardinality: CardinalityMany})
+
+	a1 := &Account{}
+	a1.Imports.Add(&Import{Subject: "dedicated", Account: epk, Type: Service},
+		&Import{Subject: "shared", Account: epk, Type: Service})
+	a2 := &Account{}
+	a2.Imports.Add(&Import{Subject: "dedicated", Account: epk, Type: Service},
+		&Import{Subject: "shared", Account: epk, Type: Service})
+	// an import of the subject from another account doesn't count
+	a3 := &Account{}
+	a3.Imports.Add(&Import{Subject: "dedicated", Account: publicKey(createAccountNKey(t), t), Type: Service})
+
+	AssertNoError(EnforceCardinality(exporter, "dedicated", map[string]*Account{epk: exporter, apk1: a1, apk3: a3}), t)
+	all := map[string]*Account{epk: exporter, apk1: a1, apk2: a2, apk3: a3}
+	if err := EnforceCardinality(exporter, "dedicated", all); err == nil {
+		t.Fatal("expected a second importer of a dedicated export to fail")
+	}
+	AssertNoError(EnforceCardinality(exporter, "shared", all), t)
+	if err := EnforceCardinality(exporter, "unknown", all); err == nil {
+		t.Fatal("expected an unknown export to fail")
+	}
+
+	vr := CreateValidationResults()
+	(&Export{Subject: "foo", Type: Service, Cardinality: "Few"}).Validate(vr)
+	if vr.IsEmpty() {
+		t.Fatal("expected this to fail due to an invalid cardinality")
+	}
+}
+
+func TestOrphanedExports(t *testing.T) {
+	apk1 := publicKey(createAccountNKey(t), t)
+	apk2 := publicKey(createAccountNKey(t), t)
+	apk3 := publicKey(createAccountNKey(t), t)
+
+	a1 := &Account{}
+	a1.Exports.Add(&Export{Subject: "orders.>", Type: Stream},
+		&Export{Subject: "billing", Type: Service},
+		&Export{Subject: "audit", Type: Stream})
+	a2 := &Account{}
+	a2.Exports.Add(&Export{Subject: "inventory", Type: Service})
+	a2.Imports.Add(&Import{Subject: "orders.new", Account: apk1, Type: Stream})
+	a3 := &Account{}
+	a3.Exports.Add(&Export{Subject: "reports", Type: Stream})
+	a3.Imports.Add(&Import{Subject: "inventory", Account: apk2, Type: Service},
+		&Import{Subject: "reports", Account: apk3, Type: Stream})
+
+	orphaned := OrphanedExports(map[string]*Account{apk1: a1, apk2: a2, apk3: a3})
+	AssertEquals(2, len(orphaned), t)
+	AssertEquals(2, len(orphaned[apk1]), t)
+	AssertEquals("audit", orphaned[apk1][0], t)
+	AssertEquals("billing", orphaned[apk1][1], t)
+	_, ok := orphaned[apk2]
+	AssertFalse(ok, t)
+	// importing your own export doesn't count
+	AssertEquals(1, len(orphaned[apk3]), t)
+	AssertEquals("reports", orphaned[apk3][0], t)
+}
+
+func TestValidateAccounts(t *testing.T) {
+	okp := createOperatorNKey(t)
+	tokens := make(map[string]string)
+	for i := 0; i < 20; i++ {
+		apk := publicKey(createAccountNKey(t), t)
+		tokens[apk] = encode(NewAccountClaims(apk), okp, t)
+	}
+
+	expired := NewAccountClaims(publicKey(createAccountNKey(t), t))
+	expired.Expires = time.Now().Add(-time.Hour).Unix()
+	tokens[expired.Subject] = encode(expired, okp, t)
+
+	invalid := NewAccountClaims(publicKey(createAccountNKey(t), t))
+	invalid.Imports.Add(&Import{Subject: "foo", Type: Stream})
+	tokens[invalid.Subject] = encode(invalid, okp, t)
+
+	mismatch := publicKey(createAccountNKey(t), t)
+	tokens[mismatch] = tokens[expired.Subject]
+	tokens["garbage"] = "not a token"
+
+	var calls, last int32
+	errs := ValidateAccounts(tokens, 4, func(done, total int) {
+		atomic.AddInt32(&calls, 1)
+		atomic.StoreInt32(&last, int32(done))
+		AssertEquals(len(tokens), total, t)
+	})
+	AssertEquals(len(tokens), int(calls), t)
+	AssertEquals(len(tokens), int(last), t)
+	AssertEquals(4, len(errs), t)
+	for _, k := range []string{expired.Subject, invalid.Subject, mismatch, "garbage"} {
+		AssertTrue(errs[k] != nil, t)
+	}
+
+	AssertEquals(0, len(ValidateAccounts(nil, 0, nil)), t)
+}
+
+func TestUnresolvedImports(t *testing.T) {
+	ekp := createAccountNKey(t)
+	epk := publicKey(ekp, t)
+	apk := publicKey(createAccountNKey(t), t)
+
+	exporter := &Account{}
+	exporter.Exports.Add(&Export{Subject: "private", Type: Service, TokenReq: true},
+		&Export{Subject: "public", Type: Stream})
+
+	expired := NewActivationClaims(apk)
+	expired.ImportSubject = "private"
+	expired.ImportType = Service
+	expired.Expires = time.Now().Add(-time.Hour).Unix()
+
+	a := &Account{}
+	a.Imports.Add(&Import{Subject: "private", Account: "bad", Type: Service},
+		&Import{Subject: "private", Account: epk, Type: Service},
+		&Import{Subject: "public", Account: epk, Type: Stream},
+		&Import{Subject: "private", Account: epk, Type: Service, Token: encode(expired, ekp, t)},
+		&Import{Subject: "other", Account: epk, Type: Stream},
+		&Import{Subject: "foo", Account: publicKey(createAccountNKey(t), t), Type: Stream})
+	accounts := map[string]*Account{epk: exporter, apk: a}
+
+	u := a.UnresolvedImports(accounts)
+	AssertEquals(5, len(u), t)
+	expected := []struct {
+		index  int
+		reason UnresolvedReason
+	}{
+		{0, UnresolvedBadKey},
+		{1, UnresolvedMissingActivation},
+		{3, UnresolvedExpiredActivation},
+		{4, UnresolvedNoExport},
+		{5, UnresolvedUnknownAccount},
+	}
+	for i, e := range expected {
+		AssertEquals(e.index, u[i].Index, t)
+		AssertEquals(e.reason, u[i].Reason, t)
+	}
+	AssertEquals("bad", u[0].Reference, t)
+	AssertEquals(Subject("private"), u[1].Subject, t)
+
+	// without the other accounts only the imports themselves are checked
+	u = a.UnresolvedImports(nil)
+	AssertEquals(2, len(u), t)
+	AssertEquals(UnresolvedBadKey, u[0].Reason, t)
+	AssertEquals(UnresolvedExpiredActivation, u[1].Reason, t)
+
+	// an import past its expiry is unresolved even if it resolves
+	timed := &Account{}
+	timed.Imports.Add(&Import{Subject: "public", Account: epk, Type: Stream, ExpiresAt: time.Now().Add(-time.Minute).Unix()},
+		&Import{Subject: "public", Account: epk, Type: Stream, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	u = timed.UnresolvedImports(accounts)
+	AssertEquals(1, len(u), t)
+	AssertEquals(0, u[0].Index, t)
+	AssertEquals(UnresolvedExpiredImport, u[0].Reason, t)
+
+	// a * export doesn't resolve a > import
+	narrow := &Account{}
+	narrow.Exports.Add(&Export{Subject: "orders.*", Type: Stream})
+	wide := &Account{}
+	wide.Imports.Add(&Import{Subject: "orders.>", Account: epk, Type: Stream},
+		&Import{Subject: "orders.*", Account: epk, Type: Stream})
+	u = wide.UnresolvedImports(map[string]*Account{epk: narrow})
+	AssertEquals(1, len(u), t)
+	AssertEquals(0, u[0].Index, t)
+	AssertEquals(UnresolvedNoExport, u[0].Reason, t)
+}
+
+func TestReserveExport(t *testing.T) {
+	apk1 := publicKey(createAccountNKey(t), t)
+	apk2 := publicKey(createAccountNKey(t), t)
+	registry := make(map[string]string)
+
+	AssertNoError(ReserveExport(registry, apk1, "orders.>"), t)
+	// the owner can export overlapping subjects
+	AssertNoError(ReserveExport(registry, apk1, "orders.new"), t)
+	AssertNoError(ReserveExport(registry, apk2, "billing.*"), t)
+
+	for _, s := range []string{"orders.new", "orders.*", "*.new", ">"} {
+		AssertTrue(ReserveExport(registry, apk2, s) != nil, t)
+	}
+	AssertTrue(ReserveExport(registry, apk1, "billing.x") != nil, t)
+	AssertTrue(ReserveExport(registry, apk2, "bad..subject") != nil, t)
+	AssertNoError(ReserveExport(registry, apk2, "billing"), t)
+
+	AssertEquals(4, len(registry), t)
+	AssertEquals(apk1, registry["orders.>"], t)
+	AssertEquals(apk2, registry["billing"], t)
+}
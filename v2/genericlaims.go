@@ -16,9 +16,13 @@
 package jwt
 
 import (
+	"crypto/rand"
+	"encoding/base32"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"strings"
+	"time"
 
 	"github.com/nats-io/nkeys"
 )
@@ -26,7 +30,12 @@ import (
 // GenericClaims can be used to read a JWT as a map for any non-generic fields
 type GenericClaims struct {
 	ClaimsData
-	Data map[string]interface{} `json:"nats,omitempty"`
+	// Nonce is an optional single use value, see NonceStore
+	Nonce string `json:"nonce,omitempty"`
+	// Confirmation is the public key of the client the token is bound to, see VerifyPossession.
+	// It isn't stored as cnf, which RFC 7800 defines as an object.
+	Confirmation string                 `json:"confirmation_key,omitempty"`
+	Data         map[string]interface{} `json:"nats,omitempty"`
 }
 
 // NewGenericClaims creates a map-based Claims
@@ -77,6 +86,9 @@ func DecodeGeneric(token string) (*GenericClaims, error) {
 		if !gc.verify(chunks[1], sig) {
 			return nil, errors.New("claim failed V1 signature verification")
 		}
+		if gc.GenericClaims.Data == nil {
+			gc.GenericClaims.Data = make(map[string]interface{})
+		}
 		if tp := gc.GenericFields.Type; tp != "" {
 			// the conversion needs to be from a string because
 			// on custom types the type is not going to be one of
@@ -95,6 +107,68 @@ func DecodeGeneric(token string) (*GenericClaims, error) {
 	return &gc.GenericClaims, nil
 }
 
+// DecodeGenericSafe decodes the token like DecodeGeneric, but returns an error
+// rather than panicking whatever the input is. It is meant for tokens from
+// untrusted sources.
+func DecodeGenericSafe(token string) (claims *GenericClaims, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			claims, err = nil, fmt.Errorf("unable to decode token: %v", r)
+		}
+	}()
+	return DecodeGeneric(token)
+}
+
+// SetRandomNonce sets the nonce to a new random value
+func (gc *GenericClaims) SetRandomNonce() error {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	gc.Nonce = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)
+	return nil
+}
+
+// VerifyPossession checks that clientProof is a signature of message made with
+// the private key of the Confirmation public key, proving the client holds it.
+func (gc *GenericClaims) VerifyPossession(clientProof []byte, message []byte) error {
+	if gc.Confirmation == "" {
+		return errors.New("claim has no confirmation key")
+	}
+	kp, err := nkeys.FromPublicKey(gc.Confirmation)
+	if err != nil {
+		return fmt.Errorf("invalid confirmation key: %v", err)
+	}
+	if err := kp.Verify(message, clientProof); err != nil {
+		return errors.New("proof of possession failed verification")
+	}
+	return nil
+}
+
+// MetadataJSON returns the issuer, subject, id, type and expiration of the
+// claim as JSON. The expiration is included as unix time (exp) and as
+// RFC3339 timestamp (exp_rfc3339).
+func (gc *GenericClaims) MetadataJSON() ([]byte, error) {
+	m := struct {
+		Issuer         string    `json:"iss,omitempty"`
+		Subject        string    `json:"sub,omitempty"`
+		ID             string    `json:"jti,omitempty"`
+		Type           ClaimType `json:"type,omitempty"`
+		Expires        int64     `json:"exp,omitempty"`
+		ExpiresRFC3339 string    `json:"exp_rfc3339,omitempty"`
+	}{
+		Issuer:  gc.Issuer,
+		Subject: gc.Subject,
+		ID:      gc.ID,
+		Type:    gc.ClaimType(),
+		Expires: gc.Expires,
+	}
+	if gc.Expires > 0 {
+		m.ExpiresRFC3339 = time.Unix(gc.Expires, 0).UTC().Format(time.RFC3339)
+	}
+	return json.Marshal(m)
+}
+
 // Claims returns the standard part of the generic claim
 func (gc *GenericClaims) Claims() *ClaimsData {
 	return &gc.ClaimsData
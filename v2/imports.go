@@ -15,6 +15,13 @@
 
 package jwt
 
+import (
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nkeys"
+)
+
 // Import describes a mapping from another account into this one
 type Import struct {
 	Name string `json:"name,omitempty"`
@@ -40,6 +47,13 @@ type Import struct {
 	LocalSubject RenamingSubject `json:"local_subject,omitempty"`
 	Type         ExportType      `json:"type,omitempty"`
 	Share        bool            `json:"share,omitempty"`
+	// ExpectedKey pins the public key the imported account has to resolve to.
+	ExpectedKey string `json:"expected_key,omitempty"`
+	// ExpectedContractVersion is the contract version of the export the import was built against.
+	ExpectedContractVersion int `json:"expected_contract_version,omitempty"`
+	// ExpiresAt is the unix time after which the import is inactive, regardless
+	// of the expiry of its activation token. 0 means the import doesn't expire.
+	ExpiresAt int64 `json:"expires_at,omitempty"`
 }
 
 // IsService returns true if the import is of type service
@@ -57,6 +71,21 @@ func (i *Import) GetTo() string {
 	return string(i.To)
 }
 
+// VerifyResolvedAccount returns an error if ExpectedKey is set and the account
+// the import resolved to has a different public key
+func (i *Import) VerifyResolvedAccount(resolvedPK string) error {
+	if i.ExpectedKey != "" && i.ExpectedKey != resolvedPK {
+		return fmt.Errorf("import %q resolved to account %q, expected %q", i.Subject, resolvedPK, i.ExpectedKey)
+	}
+	return nil
+}
+
+// IsActive returns false if the import expired before now. An expired import
+// is still valid, but it no longer grants access.
+func (i *Import) IsActive(now time.Time) bool {
+	return i.ExpiresAt == 0 || now.Unix() <= i.ExpiresAt
+}
+
 // Validate checks if an import is valid for the wrapping account
 func (i *Import) Validate(actPubKey string, vr *ValidationResults) {
 	if i == nil {
@@ -71,6 +100,16 @@ func (i *Import) Validate(actPubKey string, vr *ValidationResults) {
 		vr.AddError("account to import from is not specified")
 	}
 
+	if i.ExpectedKey != "" && !nkeys.IsValidPublicAccountKey(i.ExpectedKey) {
+		vr.AddError("expected key %q is not an account public key", i.ExpectedKey)
+	}
+	if i.ExpectedContractVersion < 0 {
+		vr.AddError("negative expected contract version is invalid")
+	}
+	if i.ExpiresAt < 0 {
+		vr.AddError("negative import expiry is invalid")
+	}
+
 	if i.GetTo() != "" {
 		vr.AddWarning("the field to has been deprecated (use LocalSubject instead)")
 	}
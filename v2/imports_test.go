@@ -498,3 +498,73 @@ func TestImports_Validate(t *testing.T) {
 		}
 	}
 }
+
+func TestImportExpectedKey(t *testing.T) {
+	pk := publicKey(createAccountNKey(t), t)
+	other := publicKey(createAccountNKey(t), t)
+
+	i := &Import{Subject: "foo", Account: pk, Type: Stream}
+	AssertNoError(i.VerifyResolvedAccount(other), t)
+
+	i.ExpectedKey = pk
+	vr := CreateValidationResults()
+	i.Validate("", vr)
+	if !vr.IsEmpty() {
+		t.Fatalf("expected pinned import to validate: %v", vr.Issues[0])
+	}
+	AssertNoError(i.VerifyResolvedAccount(pk), t)
+	if err := i.VerifyResolvedAccount(other); err == nil {
+		t.Fatal("expected resolution to a different key to fail")
+	}
+
+	i.ExpectedKey = publicKey(createUserNKey(t), t)
+	vr = CreateValidationResults()
+	i.Validate("", vr)
+	if !vr.IsBlocking(false) {
+		t.Fatal("expected non account pinned key to be blocking")
+	}
+}
+
+func TestImportExpiresAt(t *testing.T) {
+	pk := publicKey(createAccountNKey(t), t)
+	now := time.Now()
+	i := &Import{Subject: "foo", Account: pk, Type: Stream}
+	AssertTrue(i.IsActive(now), t)
+
+	i.ExpiresAt = now.Add(time.Hour).Unix()
+	AssertTrue(i.IsActive(now), t)
+	AssertFalse(i.IsActive(now.Add(2*time.Hour)), t)
+
+	// an expired import is still valid
+	i.ExpiresAt = now.Add(-time.Hour).Unix()
+	AssertFalse(i.IsActive(now), t)
+	vr := CreateValidationResults()
+	i.Validate("", vr)
+	AssertTrue(vr.IsEmpty(), t)
+
+	i.ExpiresAt = -1
+	vr = CreateValidationResults()
+	i.Validate("", vr)
+	AssertFalse(vr.IsEmpty(), t)
+}
+
+func TestImportExportUnknownTypeFailsDecode(t *testing.T) {
+	okp := createOperatorNKey(t)
+	apk := publicKey(createAccountNKey(t), t)
+	for _, field := range []string{"imports", "exports"} {
+		gc := NewGenericClaims(apk)
+		gc.Data["type"] = AccountClaim
+		gc.Data[field] = []interface{}{
+			map[string]interface{}{"subject": "foo", "account": apk, "type": "queue"},
+		}
+		token := encode(gc, okp, t)
+
+		_, err := DecodeAccountClaims(token)
+		if err == nil {
+			t.Fatalf("expected %s with unknown type to fail decoding", field)
+		}
+		if !strings.Contains(err.Error(), `"queue"`) {
+			t.Fatalf("expected error to name the bad type: %v", err)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright 2023 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwt
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// nonceSweepInterval is how often VerifyOnce drops the nonces of expired tokens
+const nonceSweepInterval = time.Minute
+
+// maxNonExpiringNonces is the number of nonces of tokens without an expiry a
+// NonceStore accepts
+const maxNonExpiringNonces = 100000
+
+// NonceStore tracks the nonces of verified tokens so that single use
+// tokens can't be replayed. It is safe for concurrent use.
+//
+// Expired tokens can't be replayed, so their nonces are dropped, at most once
+// a minute. Nonces of tokens without an expiry have to be kept for the life
+// of the store. To bound its size, only 100000 of them are accepted, after which
+// such tokens are rejected. Single use tokens should therefore expire.
+type NonceStore struct {
+	mu             sync.Mutex
+	seen           map[string]int64
+	nextSweep      int64
+	nonExpiring    int
+	maxNonExpiring int
+}
+
+// NewNonceStore creates an empty NonceStore
+func NewNonceStore() *NonceStore {
+	return &NonceStore{seen: make(map[string]int64), maxNonExpiring: maxNonExpiringNonces}
+}
+
+// VerifyOnce decodes and verifies the token and records its nonce.
+// An error is returned if the token is invalid, expired, carries no
+// nonce or if the nonce was seen before. Tokens without an expiry are
+// rejected once the store holds the maximum number of their nonces.
+func (ns *NonceStore) VerifyOnce(token string) (*GenericClaims, error) {
+	gc, err := DecodeGeneric(token)
+	if err != nil {
+		return nil, err
+	}
+	vr := CreateValidationResults()
+	gc.Validate(vr)
+	for _, i := range vr.Issues {
+		if i.Blocking || i.TimeCheck {
+			return nil, errors.New(i.Description)
+		}
+	}
+	if gc.Nonce == "" {
+		return nil, errors.New("token doesn't contain a nonce")
+	}
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	now := time.Now().UTC().Unix()
+	if now >= ns.nextSweep {
+		ns.sweep(now)
+		ns.nextSweep = now + int64(nonceSweepInterval/time.Second)
+	}
+	if _, ok := ns.seen[gc.Nonce]; ok {
+		return nil, errors.New("token nonce has already been used")
+	}
+	if gc.Expires == 0 {
+		if ns.nonExpiring >= ns.maxNonExpiring {
+			return nil, errors.New("too many single use tokens without expiry")
+		}
+		ns.nonExpiring++
+	}
+	ns.seen[gc.Nonce] = gc.Expires
+	return gc, nil
+}
+
+// sweep drops the nonces of tokens that expired before now
+func (ns *NonceStore) sweep(now int64) {
+	for n, exp := range ns.seen {
+		if exp > 0 && exp < now {
+			delete(ns.seen, n)
+		}
+	}
+}
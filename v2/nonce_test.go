@@ -0,0 +1,113 @@
+/*
+ * Copyright 2023 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNonceStoreVerifyOnce(t *testing.T) {
+	akp := createAccountNKey(t)
+	gc := NewGenericClaims(publicKey(akp, t))
+	gc.Expires = time.Now().Add(time.Hour).UTC().Unix()
+	AssertNoError(gc.SetRandomNonce(), t)
+	if gc.Nonce == "" {
+		t.Fatal("expected a nonce to be set")
+	}
+	token := encode(gc, akp, t)
+
+	ns := NewNonceStore()
+	gc2, err := ns.VerifyOnce(token)
+	AssertNoError(err, t)
+	AssertEquals(gc.Nonce, gc2.Nonce, t)
+
+	if _, err := ns.VerifyOnce(token); err == nil {
+		t.Fatal("expected replayed token to be rejected")
+	}
+
+	// a different nonce is accepted
+	AssertNoError(gc.SetRandomNonce(), t)
+	_, err = ns.VerifyOnce(encode(gc, akp, t))
+	AssertNoError(err, t)
+}
+
+func TestNonceStoreRejects(t *testing.T) {
+	akp := createAccountNKey(t)
+	ns := NewNonceStore()
+
+	gc := NewGenericClaims(publicKey(akp, t))
+	if _, err := ns.VerifyOnce(encode(gc, akp, t)); err == nil {
+		t.Fatal("expected token without nonce to be rejected")
+	}
+
+	AssertNoError(gc.SetRandomNonce(), t)
+	gc.Expires = time.Now().Add(-time.Hour).UTC().Unix()
+	if _, err := ns.VerifyOnce(encode(gc, akp, t)); err == nil {
+		t.Fatal("expected expired token to be rejected")
+	}
+}
+
+func TestNonceStoreSweep(t *testing.T) {
+	akp := createAccountNKey(t)
+	token := func(exp int64) string {
+		gc := NewGenericClaims(publicKey(akp, t))
+		gc.Expires = exp
+		AssertNoError(gc.SetRandomNonce(), t)
+		return encode(gc, akp, t)
+	}
+	ns := NewNonceStore()
+	ns.seen["expired"] = time.Now().Add(-time.Hour).Unix()
+	_, err := ns.VerifyOnce(token(time.Now().Add(time.Hour).Unix()))
+	AssertNoError(err, t)
+	_, ok := ns.seen["expired"]
+	AssertFalse(ok, t)
+
+	// expired nonces are only dropped once per interval
+	ns.seen["expired"] = time.Now().Add(-time.Hour).Unix()
+	_, err = ns.VerifyOnce(token(time.Now().Add(time.Hour).Unix()))
+	AssertNoError(err, t)
+	_, ok = ns.seen["expired"]
+	AssertTrue(ok, t)
+	ns.nextSweep = 0
+	_, err = ns.VerifyOnce(token(time.Now().Add(time.Hour).Unix()))
+	AssertNoError(err, t)
+	_, ok = ns.seen["expired"]
+	AssertFalse(ok, t)
+	AssertEquals(3, len(ns.seen), t)
+}
+
+func TestNonceStoreNonExpiringLimit(t *testing.T) {
+	akp := createAccountNKey(t)
+	token := func(exp int64) string {
+		gc := NewGenericClaims(publicKey(akp, t))
+		gc.Expires = exp
+		AssertNoError(gc.SetRandomNonce(), t)
+		return encode(gc, akp, t)
+	}
+	ns := NewNonceStore()
+	ns.maxNonExpiring = 2
+	for i := 0; i < 2; i++ {
+		_, err := ns.VerifyOnce(token(0))
+		AssertNoError(err, t)
+	}
+	if _, err := ns.VerifyOnce(token(0)); err == nil {
+		t.Fatal("expected token without expiry beyond the limit to be rejected")
+	}
+	// tokens that expire are still accepted
+	_, err := ns.VerifyOnce(token(time.Now().Add(time.Hour).Unix()))
+	AssertNoError(err, t)
+}
@@ -166,6 +166,7 @@ func NewOperatorClaims(subject string) *OperatorClaims {
 	}
 	c := &OperatorClaims{}
 	c.Subject = subject
+	c.Expires = defaultExpires(OperatorClaim)
 	c.Issuer = subject
 	return c
 }
@@ -0,0 +1,566 @@
+/*
+ * Copyright 2023 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwt
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// minimizeThreshold is the number of sibling subjects needed before
+// MinimizePermissions replaces them with a wildcard
+const minimizeThreshold = 3
+
+// MinimizePermissions suggests a minimal allow list covering the used subjects.
+// Sibling subjects that only differ in their last token are replaced with a
+// single * wildcard once there are at least 3 of them, and entries covered by
+// another entry are dropped. A > is never considered covered by a *. Usage carries no direction, so the same list is
+// set for pub and sub.
+func MinimizePermissions(used []string) Permissions {
+	siblings := make(map[string]StringList)
+	var subjects StringList
+	for _, s := range used {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		idx := strings.LastIndex(s, ".")
+		if idx <= 0 || Subject(s).HasWildCards() {
+			subjects.Add(s)
+			continue
+		}
+		prefix := s[:idx]
+		l := siblings[prefix]
+		l.Add(s)
+		siblings[prefix] = l
+	}
+	for prefix, l := range siblings {
+		if len(l) >= minimizeThreshold {
+			subjects.Add(prefix + ".*")
+		} else {
+			subjects.Add(l...)
+		}
+	}
+	var allow StringList
+	for i, s := range subjects {
+		covered := false
+		for j, o := range subjects {
+			// of entries matching the same subjects only the first is kept
+			if i != j && patternCovers(o, s) && (!patternCovers(s, o) || j < i) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			allow.Add(s)
+		}
+	}
+	sort.Strings(allow)
+	var p Permissions
+	p.Pub.Allow = allow
+	p.Sub.Allow = append(StringList{}, allow...)
+	return p
+}
+
+// RemoveInheritedPrefix marks a deny entry of a child account that removes the
+// entry following it from the allow list inherited from the parent account
+const RemoveInheritedPrefix = "!"
+
+// EffectivePermissions returns the default permissions of the child account
+// combined with the ones inherited from the parent. Allow and deny entries of
+// both accounts are combined, except for inherited allow entries the child
+// removes with a deny entry of the form !<entry>. Such markers are not part of
+// the result. The child's response permission replaces the parent's.
+//
+// A parent with an empty allow list permits all subjects, which is inherited as
+// >, so the result permits all subjects unless the child removes >. If the child
+// removes all inherited allow entries without granting any, the removed entries
+// are both allowed and denied, so that the result permits no subjects rather
+// than ending up with an empty allow list. Without a parent the child's own
+// permissions are returned.
+func (child *Account) EffectivePermissions(parent *Account) Permissions {
+	inherit := func(c, p Permission) Permission {
+		var r Permission
+		removed := make(map[string]bool)
+		for _, d := range c.Deny {
+			if strings.HasPrefix(d, RemoveInheritedPrefix) {
+				removed[strings.TrimPrefix(d, RemoveInheritedPrefix)] = true
+			}
+		}
+		inherited := p.Allow
+		if parent != nil && len(inherited) == 0 {
+			inherited = StringList{">"}
+		}
+		var dropped StringList
+		for _, a := range inherited {
+			if removed[a] {
+				dropped.Add(a)
+			} else {
+				r.Allow.Add(a)
+			}
+		}
+		r.Allow.Add(c.Allow...)
+		r.Deny.Add(p.Deny...)
+		for _, d := range c.Deny {
+			if !strings.HasPrefix(d, RemoveInheritedPrefix) {
+				r.Deny.Add(d)
+			}
+		}
+		switch {
+		case r.Allow.Contains(">"):
+			// all subjects are allowed, which an empty allow list expresses
+			r.Allow = nil
+		case len(r.Allow) == 0 && len(dropped) > 0:
+			r.Allow.Add(dropped...)
+			r.Deny.Add(dropped...)
+		}
+		return r
+	}
+	var p Permissions
+	if parent != nil {
+		p = parent.DefaultPermissions
+	}
+	c := child.DefaultPermissions
+	e := Permissions{Pub: inherit(c.Pub, p.Pub), Sub: inherit(c.Sub, p.Sub)}
+	resp := p.Resp
+	if c.Resp != nil {
+		resp = c.Resp
+	}
+	if resp != nil {
+		r := *resp
+		e.Resp = &r
+	}
+	return e
+}
+
+// SplitWildcard returns the sorted concrete subjects in observed that match the
+// pattern, so that a wildcard grant can be replaced by the subjects actually used.
+// Observed subjects containing wildcards are ignored.
+func SplitWildcard(pattern string, observed []string) StringList {
+	var r StringList
+	for _, o := range observed {
+		subj := Subject(o)
+		if !subj.HasWildCards() && subj.IsContainedIn(Subject(pattern)) {
+			r.Add(o)
+		}
+	}
+	sort.Strings(r)
+	return r
+}
+
+// ScopeTo returns a copy of the permissions narrowed to the subject prefix and
+// its sub subjects. Allow and deny entries overlapping the prefix are narrowed
+// to it, the others are dropped. An empty allow list permits all subjects and
+// is narrowed like >. If no allow entry overlaps the prefix, the direction is
+// scoped to allow and deny the prefix, so that it permits no subjects rather
+// than ending up with an empty allow list.
+func (p Permissions) ScopeTo(prefix string) Permissions {
+	scope := []string{prefix, prefix + ".>"}
+	narrow := func(l StringList) StringList {
+		var r StringList
+		for _, e := range l {
+			r.Add(narrowEntry(e, prefix)...)
+		}
+		return r
+	}
+	scopePermission := func(perm Permission) Permission {
+		allow := perm.Allow
+		if len(allow) == 0 {
+			allow = StringList{">"}
+		}
+		r := Permission{Allow: narrow(allow), Deny: narrow(perm.Deny)}
+		if len(r.Allow) == 0 {
+			r.Allow.Add(scope...)
+			r.Deny.Add(scope...)
+		}
+		return r
+	}
+	s := Permissions{Pub: scopePermission(p.Pub), Sub: scopePermission(p.Sub)}
+	if p.Resp != nil {
+		resp := *p.Resp
+		s.Resp = &resp
+	}
+	return s
+}
+
+// narrowEntry returns the permission entry narrowed to the prefix and its sub
+// subjects, keeping its queue. An entry can narrow to several subjects, e.g.
+// > narrows to the prefix and prefix.>, or to none if it doesn't overlap.
+func narrowEntry(entry string, prefix string) []string {
+	if isUnderPrefix(entry, prefix) {
+		return []string{entry}
+	}
+	subj, queue, hasQueue := strings.Cut(entry, " ")
+	es, err := NewSubjectSet(subj)
+	if err != nil {
+		return nil
+	}
+	scope, err := NewSubjectSet(prefix, prefix+".>")
+	if err != nil {
+		return nil
+	}
+	// the intersection of subject patterns can always be described exactly
+	narrowed := es.Intersect(scope).Patterns()
+	if hasQueue {
+		for i := range narrowed {
+			narrowed[i] += " " + queue
+		}
+	}
+	return narrowed
+}
+
+// EnforceNamespace returns an error if the publish or subscribe permissions allow
+// a subject that doesn't fall under tenantPrefix. As an empty allow list permits
+// all subjects, both allow lists need to be set. Deny entries aren't checked.
+func EnforceNamespace(p Permissions, tenantPrefix string) error {
+	tenantPrefix = strings.TrimSuffix(tenantPrefix, ".")
+	if tenantPrefix == "" {
+		return errors.New("tenant prefix is required")
+	}
+	check := func(kind string, l StringList) error {
+		if len(l) == 0 {
+			return fmt.Errorf("%s permissions allow all subjects, outside of namespace %q", kind, tenantPrefix)
+		}
+		for _, e := range l {
+			if !isUnderPrefix(e, tenantPrefix) {
+				return fmt.Errorf("%s permission %q is outside of namespace %q", kind, e, tenantPrefix)
+			}
+		}
+		return nil
+	}
+	if err := check("publish", p.Pub.Allow); err != nil {
+		return err
+	}
+	return check("subscribe", p.Sub.Allow)
+}
+
+// IsEscalation returns true if the new permissions grant access to subjects the
+// old permissions didn't. The returned list holds the newly accessible publish
+// and subscribe entries, prefixed with pub or sub, and resp if the response
+// permission limits were raised. An empty allow list allows all subjects, and
+// an entry without a queue covers all queues, so widening an entry to all
+// queues or narrowing a deny to a queue is an escalation too.
+func IsEscalation(old, new Permissions) (bool, []string) {
+	var gained []string
+	check := func(kind string, o, n Permission) {
+		oldAllow, newAllow := o.Allow, n.Allow
+		if len(oldAllow) == 0 {
+			oldAllow = StringList{">"}
+		}
+		if len(newAllow) == 0 {
+			newAllow = StringList{">"}
+		}
+		for _, e := range newAllow {
+			if !entryCovered(e, oldAllow) && !entryCovered(e, n.Deny) {
+				gained = append(gained, kind+" "+e)
+			}
+		}
+		// subjects no longer denied are accessible again if the new permissions allow them
+		for _, d := range o.Deny {
+			if !entryCovered(d, n.Deny) && entryOverlaps(d, newAllow) {
+				gained = append(gained, kind+" "+d)
+			}
+		}
+	}
+	check("pub", old.Pub, new.Pub)
+	check("sub", old.Sub, new.Sub)
+	if new.Resp != nil {
+		if old.Resp == nil || new.Resp.MaxMsgs > old.Resp.MaxMsgs || new.Resp.Expires > old.Resp.Expires {
+			gained = append(gained, "resp")
+		}
+	}
+	return len(gained) > 0, gained
+}
+
+// entryCovered returns true if one of the permission entries in l matches all
+// subjects and queues the entry matches. Entries without a queue match all queues.
+func entryCovered(entry string, l StringList) bool {
+	subj, queue, _ := strings.Cut(entry, " ")
+	for _, e := range l {
+		es, eq, _ := strings.Cut(e, " ")
+		if patternCovers(es, subj) && (eq == "" || (queue != "" && patternCovers(eq, queue))) {
+			return true
+		}
+	}
+	return false
+}
+
+// entryOverlaps returns true if a subject and queue exist that both the entry
+// and one of the entries in l match
+func entryOverlaps(entry string, l StringList) bool {
+	subj, queue, _ := strings.Cut(entry, " ")
+	for _, e := range l {
+		es, eq, _ := strings.Cut(e, " ")
+		if patternsOverlap(es, subj) && (eq == "" || queue == "" || patternsOverlap(eq, queue)) {
+			return true
+		}
+	}
+	return false
+}
+
+// patternCovers returns true if all subjects matching pattern also match other.
+// Unlike Subject.IsContainedIn a > is never covered by a *.
+func patternCovers(other, pattern string) bool {
+	s, err := NewSubjectSet(other)
+	return err == nil && s.Contains(pattern)
+}
+
+// patternsOverlap returns true if a subject exists that matches both patterns
+func patternsOverlap(a, b string) bool {
+	as, err := NewSubjectSet(a)
+	if err != nil {
+		return false
+	}
+	bs, err := NewSubjectSet(b)
+	return err == nil && as.Overlaps(bs)
+}
+
+// isUnderPrefix returns true if the subject of the permission entry, ignoring
+// a queue, is the prefix or one of its sub subjects
+func isUnderPrefix(entry string, prefix string) bool {
+	subj := Subject(strings.Split(entry, " ")[0])
+	return string(subj) == prefix || subj.IsContainedIn(Subject(prefix+".>"))
+}
+
+// Merge returns the union of both permissions. An empty allow list permits all
+// subjects, so the result does too if either permission's allow list is empty.
+// A direction that denies all subjects it allows grants nothing and doesn't
+// contribute to the result, which is how a template leaves out a direction,
+// e.g. a read only template denies > for publish. A deny entry of one
+// permission is dropped if the other grants all the subjects it denies and is
+// kept otherwise. As nats permissions can't express every union, the result can
+// be narrower than the union where a deny only partly overlaps the other's
+// grants, but it is never wider. For response permissions the larger limits are
+// kept.
+func (p Permissions) Merge(other Permissions) Permissions {
+	m := Permissions{Pub: mergePermission(p.Pub, other.Pub), Sub: mergePermission(p.Sub, other.Sub)}
+	for _, r := range []*ResponsePermission{p.Resp, other.Resp} {
+		if r == nil {
+			continue
+		}
+		if m.Resp == nil {
+			m.Resp = &ResponsePermission{}
+		}
+		if r.MaxMsgs > m.Resp.MaxMsgs {
+			m.Resp.MaxMsgs = r.MaxMsgs
+		}
+		if r.Expires > m.Resp.Expires {
+			m.Resp.Expires = r.Expires
+		}
+	}
+	return m
+}
+
+func mergePermission(a, b Permission) Permission {
+	if grantsNothing(a) {
+		return copyPermission(b)
+	}
+	if grantsNothing(b) {
+		return copyPermission(a)
+	}
+	var m Permission
+	if len(a.Allow) > 0 && len(b.Allow) > 0 {
+		m.Allow.Add(a.Allow...)
+		m.Allow.Add(b.Allow...)
+	}
+	keepDenies := func(deny StringList, other Permission) {
+		granted := grantedToAllQueues(other)
+		for _, d := range deny {
+			subj, _, _ := strings.Cut(d, " ")
+			if ds, err := NewSubjectSet(subj); err == nil && ds.Subtract(granted).IsEmpty() {
+				continue
+			}
+			m.Deny.Add(d)
+		}
+	}
+	keepDenies(a.Deny, b)
+	keepDenies(b.Deny, a)
+	return m
+}
+
+func copyPermissions(p Permissions) Permissions {
+	c := Permissions{Pub: copyPermission(p.Pub), Sub: copyPermission(p.Sub)}
+	if p.Resp != nil {
+		r := *p.Resp
+		c.Resp = &r
+	}
+	return c
+}
+
+func copyPermission(p Permission) Permission {
+	var c Permission
+	c.Allow.Add(p.Allow...)
+	c.Deny.Add(p.Deny...)
+	return c
+}
+
+// subjectSetOf returns the subjects matching the permission entries, optionally
+// skipping entries limited to a queue. Invalid entries are ignored.
+func subjectSetOf(entries StringList, skipQueues bool) SubjectSet {
+	var s SubjectSet
+	for _, e := range entries {
+		subj, _, hasQueue := strings.Cut(e, " ")
+		if hasQueue && skipQueues {
+			continue
+		}
+		if es, err := NewSubjectSet(subj); err == nil {
+			s = s.Union(es)
+		}
+	}
+	return s
+}
+
+// effectiveAllow returns the allow list, or > if it is empty and permits all subjects
+func effectiveAllow(p Permission) StringList {
+	if len(p.Allow) == 0 {
+		return StringList{">"}
+	}
+	return p.Allow
+}
+
+// grantsNothing returns true if the permission denies every subject it allows
+// in any queue
+func grantsNothing(p Permission) bool {
+	return subjectSetOf(effectiveAllow(p), false).Subtract(subjectSetOf(p.Deny, true)).IsEmpty()
+}
+
+// grantedToAllQueues returns the subjects the permission grants in every queue
+func grantedToAllQueues(p Permission) SubjectSet {
+	return subjectSetOf(effectiveAllow(p), true).Subtract(subjectSetOf(p.Deny, false))
+}
+
+var (
+	templatesMu         sync.RWMutex
+	permissionTemplates = make(map[string]Permissions)
+)
+
+// RegisterPermissionTemplate registers the permissions under the name, replacing
+// a template previously registered with the same name. As an empty allow list
+// permits all subjects, a template that grants nothing in a direction has to
+// deny > in it.
+func RegisterPermissionTemplate(name string, p Permissions) {
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	permissionTemplates[name] = copyPermissions(p)
+}
+
+// ComposePermissions merges the registered templates with the specified names,
+// see Merge. An error is returned if no name is specified or a template isn't
+// registered.
+func ComposePermissions(names ...string) (Permissions, error) {
+	templatesMu.RLock()
+	defer templatesMu.RUnlock()
+	if len(names) == 0 {
+		return Permissions{}, errors.New("no permission templates specified")
+	}
+	var p Permissions
+	for i, n := range names {
+		t, ok := permissionTemplates[n]
+		if !ok {
+			return Permissions{}, fmt.Errorf("permission template %q is not registered", n)
+		}
+		if i == 0 {
+			// merging with the zero value would permit all subjects
+			p = copyPermissions(t)
+		} else {
+			p = p.Merge(t)
+		}
+	}
+	return p, nil
+}
+
+// compressMinRange is the minimum length of a numeric range CompressSubjects
+// replaces with a wildcard
+const compressMinRange = 10
+
+// CompressSubjects replaces subjects that only differ in one numeric token with
+// a single * wildcard for that token. To limit over generalizing, the numeric
+// values have to form a contiguous range starting at 0 or 1 with at least 10
+// entries. Other subjects are returned unchanged, in their original order.
+//
+// The result is wider than the input: the wildcard matches any token in its
+// position, not just the numbers of the range. t.1 to t.10 become t.*, which
+// also matches t.11 and t.admin. Only compress allow lists where nothing else
+// can exist in that position, and never compress deny lists.
+func CompressSubjects(subjects []string) []string {
+	var list StringList
+	list.Add(subjects...)
+	for changed := true; changed; {
+		changed = false
+		maxTokens := 0
+		for _, s := range list {
+			if n := strings.Count(s, ".") + 1; n > maxTokens {
+				maxTokens = n
+			}
+		}
+		for pos := 0; pos < maxTokens && !changed; pos++ {
+			if compressed, ok := compressPosition(list, pos); ok {
+				list = compressed
+				changed = true
+			}
+		}
+	}
+	return list
+}
+
+// compressPosition collapses the numeric ranges found at the token position
+func compressPosition(list StringList, pos int) (StringList, bool) {
+	groups := make(map[string][]int)
+	for _, s := range list {
+		tokens := strings.Split(s, ".")
+		if pos >= len(tokens) {
+			continue
+		}
+		n, err := strconv.Atoi(tokens[pos])
+		if err != nil || n < 0 || strconv.Itoa(n) != tokens[pos] {
+			continue
+		}
+		tokens[pos] = "*"
+		tmpl := strings.Join(tokens, ".")
+		groups[tmpl] = append(groups[tmpl], n)
+	}
+	collapse := make(map[string]bool)
+	for tmpl, values := range groups {
+		if len(values) < compressMinRange {
+			continue
+		}
+		sort.Ints(values)
+		if values[0] > 1 || values[len(values)-1]-values[0]+1 != len(values) {
+			continue
+		}
+		collapse[tmpl] = true
+	}
+	if len(collapse) == 0 {
+		return list, false
+	}
+	var r StringList
+	for _, s := range list {
+		tokens := strings.Split(s, ".")
+		if pos < len(tokens) {
+			tokens[pos] = "*"
+			if tmpl := strings.Join(tokens, "."); collapse[tmpl] && s != tmpl {
+				r.Add(tmpl)
+				continue
+			}
+		}
+		r.Add(s)
+	}
+	return r, true
+}
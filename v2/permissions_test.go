@@ -0,0 +1,436 @@
+/*
+ * Copyright 2023 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwt
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMinimizePermissions(t *testing.T) {
+	p := MinimizePermissions([]string{"foo.a", "foo.b", "foo.c", "foo.a"})
+	AssertEquals(1, len(p.Pub.Allow), t)
+	AssertEquals("foo.*", p.Pub.Allow[0], t)
+	AssertEquals(1, len(p.Sub.Allow), t)
+	AssertEquals("foo.*", p.Sub.Allow[0], t)
+	AssertEquals(true, p.Pub.Deny == nil && p.Sub.Deny == nil, t)
+
+	// too few siblings are kept as is, covered entries are dropped
+	p = MinimizePermissions([]string{"bar.a", "bar.b", "baz", "baz.x.y", "baz.>"})
+	AssertEquals(4, len(p.Pub.Allow), t)
+	AssertEquals("bar.a", p.Pub.Allow[0], t)
+	AssertEquals("bar.b", p.Pub.Allow[1], t)
+	AssertEquals("baz", p.Pub.Allow[2], t)
+	AssertEquals("baz.>", p.Pub.Allow[3], t)
+
+	p = MinimizePermissions(nil)
+	AssertEquals(0, len(p.Pub.Allow), t)
+
+	// a > isn't covered by a *, wildcards never minimize to an empty allow list
+	p = MinimizePermissions([]string{"foo.>", "foo.*"})
+	AssertEquals(1, len(p.Pub.Allow), t)
+	AssertEquals("foo.>", p.Pub.Allow[0], t)
+	AssertTrue(p.Sub.MatchesSub("foo.a.b", ""), t)
+	AssertFalse(p.Sub.MatchesSub("bar", ""), t)
+	p = MinimizePermissions([]string{"*", ">"})
+	AssertEquals(1, len(p.Pub.Allow), t)
+	AssertEquals(">", p.Pub.Allow[0], t)
+	p = MinimizePermissions([]string{"foo.*", "foo.*.>", "foo.bar"})
+	AssertEquals(2, len(p.Pub.Allow), t)
+	AssertTrue(p.Pub.MatchesSub("foo.bar", ""), t)
+	AssertTrue(p.Pub.MatchesSub("foo.bar.baz", ""), t)
+	AssertFalse(p.Pub.MatchesSub("bar", ""), t)
+}
+
+func TestPermissionsScopeTo(t *testing.T) {
+	var p Permissions
+	p.Pub.Allow.Add("orders.*", "billing.*", "orders", "ordersx.a")
+	p.Pub.Deny.Add("orders.secret", "*.secret")
+	p.Sub.Allow.Add("orders.> workers", ">")
+	p.Resp = &ResponsePermission{MaxMsgs: 1}
+
+	s := p.ScopeTo("orders")
+	AssertEquals(2, len(s.Pub.Allow), t)
+	AssertEquals("orders.*", s.Pub.Allow[0], t)
+	AssertEquals("orders", s.Pub.Allow[1], t)
+	AssertEquals(1, len(s.Pub.Deny), t)
+	AssertEquals("orders.secret", s.Pub.Deny[0], t)
+	// > is narrowed to the prefix
+	AssertEquals(3, len(s.Sub.Allow), t)
+	AssertEquals("orders.> workers", s.Sub.Allow[0], t)
+	AssertEquals("orders", s.Sub.Allow[1], t)
+	AssertEquals("orders.>", s.Sub.Allow[2], t)
+	AssertEquals(0, len(s.Sub.Deny), t)
+	AssertEquals(1, s.Resp.MaxMsgs, t)
+	AssertTrue(s.Resp != p.Resp, t)
+
+	// the original is not modified
+	AssertEquals(4, len(p.Pub.Allow), t)
+}
+
+func TestPermissionsScopeToDoesntWiden(t *testing.T) {
+	// no allow entry under the prefix doesn't turn into an empty allow list
+	var p Permissions
+	p.Pub.Allow.Add("billing.*")
+	s := p.ScopeTo("orders")
+	AssertTrue(len(s.Pub.Allow) > 0, t)
+	for _, subj := range []string{"orders", "orders.x", "billing.x", "secret.x"} {
+		AssertFalse(s.Pub.MatchesSub(subj, ""), t)
+	}
+
+	// wildcard denies overlapping the prefix are narrowed to it
+	p = Permissions{}
+	p.Sub.Allow.Add("orders.>")
+	p.Sub.Deny.Add("*.admin", "> spies", "billing.>")
+	AssertFalse(p.Sub.MatchesSub("orders.admin", ""), t)
+	s = p.ScopeTo("orders")
+	AssertEquals(3, len(s.Sub.Deny), t)
+	AssertEquals("orders.admin", s.Sub.Deny[0], t)
+	AssertEquals("orders spies", s.Sub.Deny[1], t)
+	AssertEquals("orders.> spies", s.Sub.Deny[2], t)
+	AssertFalse(s.Sub.MatchesSub("orders.admin", ""), t)
+	AssertFalse(s.Sub.MatchesSub("orders.new", "spies"), t)
+	AssertTrue(s.Sub.MatchesSub("orders.new", "workers"), t)
+
+	// an empty allow list is scoped to the prefix
+	s = Permissions{}.ScopeTo("orders")
+	AssertTrue(s.Pub.MatchesSub("orders.new", ""), t)
+	AssertFalse(s.Pub.MatchesSub("billing.new", ""), t)
+}
+
+func TestCompressSubjects(t *testing.T) {
+	var subjects []string
+	for i := 1; i <= 1000; i++ {
+		subjects = append(subjects, fmt.Sprintf("t.%d.>", i))
+	}
+	subjects = append(subjects, "other")
+	c := CompressSubjects(subjects)
+	AssertEquals(2, len(c), t)
+	AssertEquals("t.*.>", c[0], t)
+	AssertEquals("other", c[1], t)
+
+	// sparse ranges stay as is
+	var sparse []string
+	for i := 1; i <= 1000; i += 7 {
+		sparse = append(sparse, fmt.Sprintf("t.%d.>", i))
+	}
+	AssertEquals(len(sparse), len(CompressSubjects(sparse)), t)
+
+	// short ranges and ranges that don't start at the beginning stay as is
+	AssertEquals(3, len(CompressSubjects([]string{"t.1", "t.2", "t.3"})), t)
+	var offset []string
+	for i := 500; i < 520; i++ {
+		offset = append(offset, fmt.Sprintf("t.%d", i))
+	}
+	AssertEquals(20, len(CompressSubjects(offset)), t)
+
+	// nested ranges collapse one token at a time
+	var nested []string
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 10; j++ {
+			nested = append(nested, fmt.Sprintf("a.%d.b.%d", i, j))
+		}
+	}
+	c = CompressSubjects(nested)
+	AssertEquals(1, len(c), t)
+	AssertEquals("a.*.b.*", c[0], t)
+
+	// the wildcard is wider than the range
+	var ten []string
+	for i := 1; i <= 10; i++ {
+		ten = append(ten, fmt.Sprintf("t.%d", i))
+	}
+	c = CompressSubjects(ten)
+	AssertEquals(1, len(c), t)
+	AssertTrue(Subject("t.11").IsContainedIn(Subject(c[0])), t)
+	AssertTrue(Subject("t.admin").IsContainedIn(Subject(c[0])), t)
+
+	// non numeric tokens are never compressed
+	var letters []string
+	for r := 'a'; r <= 'z'; r++ {
+		letters = append(letters, fmt.Sprintf("t.%c", r))
+	}
+	AssertEquals(len(letters), len(CompressSubjects(letters)), t)
+	AssertEquals(11, len(CompressSubjects(append(ten[:9:9], "t.admin", "t.x"))), t)
+}
+
+func TestEnforceNamespace(t *testing.T) {
+	var p Permissions
+	p.Pub.Allow.Add("t1.orders.>", "t1")
+	p.Sub.Allow.Add("t1.*", "t1.events q")
+	p.Sub.Deny.Add(">")
+	AssertNoError(EnforceNamespace(p, "t1"), t)
+	AssertNoError(EnforceNamespace(p, "t1."), t)
+	AssertTrue(EnforceNamespace(p, "t2") != nil, t)
+	AssertTrue(EnforceNamespace(p, "") != nil, t)
+
+	p.Sub.Allow.Add("t2.orders")
+	AssertTrue(EnforceNamespace(p, "t1") != nil, t)
+
+	// wildcards reaching outside the namespace
+	var w Permissions
+	w.Pub.Allow.Add("*.orders")
+	w.Sub.Allow.Add("t1.>")
+	AssertTrue(EnforceNamespace(w, "t1") != nil, t)
+
+	// an empty allow list allows everything
+	var e Permissions
+	e.Pub.Allow.Add("t1.>")
+	AssertTrue(EnforceNamespace(e, "t1") != nil, t)
+}
+
+func TestPermissionsMerge(t *testing.T) {
+	var a, b Permissions
+	a.Pub.Allow.Add("foo", "bar")
+	a.Sub.Deny.Add("secret")
+	a.Resp = &ResponsePermission{MaxMsgs: 1, Expires: time.Minute}
+	b.Pub.Allow.Add("bar", "baz")
+	b.Pub.Deny.Add("baz.secret")
+	b.Resp = &ResponsePermission{MaxMsgs: 5, Expires: time.Second}
+
+	m := a.Merge(b)
+	AssertEquals(3, len(m.Pub.Allow), t)
+	AssertEquals(1, len(m.Pub.Deny), t)
+	// b permits all subscriptions, secret included
+	AssertEquals(0, len(m.Sub.Allow), t)
+	AssertEquals(0, len(m.Sub.Deny), t)
+	AssertEquals(5, m.Resp.MaxMsgs, t)
+	AssertEquals(time.Minute, m.Resp.Expires, t)
+	AssertTrue(m.Resp != a.Resp && m.Resp != b.Resp, t)
+	AssertEquals(2, len(a.Pub.Allow), t)
+
+	AssertTrue(a.Merge(Permissions{}).Resp != nil, t)
+	AssertTrue(Permissions{}.Merge(Permissions{}).Resp == nil, t)
+}
+
+func TestPermissionsMergeEmptyAllow(t *testing.T) {
+	// an empty allow list permits all subjects and isn't narrowed
+	var all, x Permissions
+	x.Pub.Allow.Add("x")
+	m := all.Merge(x)
+	AssertEquals(0, len(m.Pub.Allow), t)
+	AssertTrue(m.Pub.MatchesSub("y", ""), t)
+	AssertEquals(0, len(x.Merge(all).Pub.Allow), t)
+
+	// a direction denying all subjects doesn't contribute
+	var none Permissions
+	none.Pub.Deny.Add(">")
+	m = none.Merge(x)
+	AssertEquals(1, len(m.Pub.Allow), t)
+	AssertEquals(0, len(m.Pub.Deny), t)
+	AssertTrue(m.Pub.MatchesSub("x", ""), t)
+	AssertFalse(m.Pub.MatchesSub("y", ""), t)
+	AssertFalse(none.Merge(none).Pub.MatchesSub("y", ""), t)
+
+	// denies only partly covered by the other permission are kept
+	var wide, narrow Permissions
+	wide.Pub.Deny.Add("foo.>", "bar")
+	narrow.Pub.Allow.Add("foo.x", "bar")
+	m = wide.Merge(narrow)
+	AssertEquals(0, len(m.Pub.Allow), t)
+	AssertEquals(1, len(m.Pub.Deny), t)
+	AssertFalse(m.Pub.MatchesSub("foo.y", ""), t)
+	AssertTrue(m.Pub.MatchesSub("bar", ""), t)
+}
+
+func TestComposePermissions(t *testing.T) {
+	var readonly, publisher Permissions
+	readonly.Pub.Deny.Add(">")
+	readonly.Sub.Allow.Add("orders.>", "_INBOX.>")
+	publisher.Pub.Allow.Add("orders.>")
+	publisher.Pub.Deny.Add("orders.admin")
+	publisher.Sub.Deny.Add(">")
+	RegisterPermissionTemplate("readonly", readonly)
+	RegisterPermissionTemplate("publisher", publisher)
+
+	p, err := ComposePermissions("readonly", "publisher")
+	AssertNoError(err, t)
+	AssertEquals(2, len(p.Sub.Allow), t)
+	AssertTrue(p.Pub.Allow.Contains("orders.>"), t)
+	AssertTrue(p.Pub.Deny.Contains("orders.admin"), t)
+	AssertEquals(1, len(p.Pub.Deny), t)
+	AssertEquals(0, len(p.Sub.Deny), t)
+
+	// a read only template doesn't publish
+	p, err = ComposePermissions("readonly")
+	AssertNoError(err, t)
+	AssertFalse(p.Pub.MatchesSub("orders.new", ""), t)
+
+	// templates are copied when registered
+	readonly.Sub.Allow.Add("more")
+	p, err = ComposePermissions("readonly")
+	AssertNoError(err, t)
+	AssertEquals(2, len(p.Sub.Allow), t)
+
+	_, err = ComposePermissions("readonly", "unknown")
+	AssertTrue(err != nil, t)
+	_, err = ComposePermissions()
+	AssertTrue(err != nil, t)
+}
+
+func TestIsEscalation(t *testing.T) {
+	var old, wider Permissions
+	old.Pub.Allow.Add("foo.bar")
+	old.Sub.Allow.Add("foo.bar q")
+	wider.Pub.Allow.Add("foo.>")
+	wider.Sub.Allow.Add("foo.bar")
+
+	// foo.bar in any queue is wider than foo.bar in queue q
+	esc, gained := IsEscalation(old, wider)
+	AssertTrue(esc, t)
+	AssertEquals(2, len(gained), t)
+	AssertEquals("pub foo.>", gained[0], t)
+	AssertEquals("sub foo.bar", gained[1], t)
+	AssertFalse(old.Sub.MatchesSub("foo.bar", "other"), t)
+	AssertTrue(wider.Sub.MatchesSub("foo.bar", "other"), t)
+
+	esc, gained = IsEscalation(wider, old)
+	AssertFalse(esc, t)
+	AssertEquals(0, len(gained), t)
+
+	// an empty allow list allows everything
+	var all Permissions
+	all.Sub.Allow.Add("foo.bar")
+	esc, gained = IsEscalation(old, all)
+	AssertTrue(esc, t)
+	AssertEquals("pub >", gained[0], t)
+
+	// removing a deny is an escalation, adding one isn't
+	var denied Permissions
+	denied.Pub.Allow.Add("foo.>")
+	denied.Pub.Deny.Add("foo.secret")
+	denied.Sub.Allow.Add("foo.bar")
+	esc, gained = IsEscalation(denied, wider)
+	AssertTrue(esc, t)
+	AssertEquals(1, len(gained), t)
+	AssertEquals("pub foo.secret", gained[0], t)
+	esc, _ = IsEscalation(wider, denied)
+	AssertFalse(esc, t)
+
+	// narrowing a deny to a queue
+	var queueDenied Permissions
+	queueDenied.Pub = denied.Pub
+	queueDenied.Sub.Deny.Add("foo.secret spies")
+	var subDenied Permissions
+	subDenied.Pub = denied.Pub
+	subDenied.Sub.Deny.Add("foo.secret")
+	esc, gained = IsEscalation(subDenied, queueDenied)
+	AssertTrue(esc, t)
+	AssertEquals(1, len(gained), t)
+	AssertEquals("sub foo.secret", gained[0], t)
+	esc, _ = IsEscalation(queueDenied, subDenied)
+	AssertFalse(esc, t)
+
+	// removing a wildcard deny that overlaps an allow entry
+	var narrow, open Permissions
+	narrow.Pub.Allow.Add("foo.x")
+	narrow.Pub.Deny.Add("foo.>")
+	open.Pub.Allow.Add("foo.x")
+	esc, gained = IsEscalation(narrow, open)
+	AssertTrue(esc, t)
+	AssertEquals("pub foo.>", gained[0], t)
+
+	// a > isn't covered by a *
+	var star, tail Permissions
+	star.Pub.Allow.Add("foo.*")
+	tail.Pub.Allow.Add("foo.>")
+	esc, _ = IsEscalation(star, tail)
+	AssertTrue(esc, t)
+
+	// response permissions
+	denied.Resp = &ResponsePermission{MaxMsgs: 1}
+	esc, gained = IsEscalation(wider, denied)
+	AssertTrue(esc, t)
+	AssertEquals("resp", gained[0], t)
+}
+
+func TestSplitWildcard(t *testing.T) {
+	s := SplitWildcard("orders.>", []string{"orders.new", "billing.new", "orders.cancel", "orders.new", "orders.*", "orders"})
+	AssertEquals(2, len(s), t)
+	AssertEquals("orders.cancel", s[0], t)
+	AssertEquals("orders.new", s[1], t)
+
+	AssertEquals(0, len(SplitWildcard("orders.*", []string{"orders.new.x"})), t)
+}
+
+func TestEffectivePermissions(t *testing.T) {
+	parent := &Account{}
+	parent.DefaultPermissions.Pub.Allow.Add("orders.>", "billing.>", "audit")
+	parent.DefaultPermissions.Pub.Deny.Add("orders.secret")
+	parent.DefaultPermissions.Sub.Allow.Add("events.>")
+	parent.DefaultPermissions.Resp = &ResponsePermission{MaxMsgs: 1}
+
+	child := &Account{}
+	child.DefaultPermissions.Pub.Allow.Add("child.>")
+	child.DefaultPermissions.Pub.Deny.Add("!billing.>", "audit.x")
+
+	p := child.EffectivePermissions(parent)
+	AssertEquals(3, len(p.Pub.Allow), t)
+	AssertTrue(p.Pub.Allow.Contains("orders.>"), t)
+	AssertTrue(p.Pub.Allow.Contains("audit"), t)
+	AssertTrue(p.Pub.Allow.Contains("child.>"), t)
+	AssertFalse(p.Pub.Allow.Contains("billing.>"), t)
+	AssertEquals(2, len(p.Pub.Deny), t)
+	AssertTrue(p.Pub.Deny.Contains("orders.secret"), t)
+	AssertTrue(p.Pub.Deny.Contains("audit.x"), t)
+	AssertEquals(1, len(p.Sub.Allow), t)
+	AssertEquals(1, p.Resp.MaxMsgs, t)
+	AssertTrue(p.Resp != parent.DefaultPermissions.Resp, t)
+
+	// the child can't remove what it grants itself
+	child.DefaultPermissions.Pub.Deny.Add("!child.>")
+	p = child.EffectivePermissions(parent)
+	AssertTrue(p.Pub.Allow.Contains("child.>"), t)
+
+	child.DefaultPermissions.Resp = &ResponsePermission{MaxMsgs: 5}
+	AssertEquals(5, child.EffectivePermissions(parent).Resp.MaxMsgs, t)
+	AssertEquals(1, len(child.EffectivePermissions(nil).Pub.Allow), t)
+}
+
+func TestEffectivePermissionsEmptyAllow(t *testing.T) {
+	// removing the last inherited allow entry doesn't permit all subjects
+	parent := &Account{}
+	parent.DefaultPermissions.Pub.Allow.Add("billing.>")
+	child := &Account{}
+	child.DefaultPermissions.Pub.Deny.Add("!billing.>")
+	p := child.EffectivePermissions(parent)
+	AssertTrue(len(p.Pub.Allow) > 0, t)
+	AssertFalse(p.Pub.MatchesSub("secret.x", ""), t)
+	AssertFalse(p.Pub.MatchesSub("billing.x", ""), t)
+
+	// a parent permitting all subjects isn't narrowed by the child's allow entries
+	parent = &Account{}
+	child = &Account{}
+	child.DefaultPermissions.Pub.Allow.Add("child.>")
+	child.DefaultPermissions.Pub.Deny.Add("secret.>")
+	p = child.EffectivePermissions(parent)
+	AssertEquals(0, len(p.Pub.Allow), t)
+	AssertTrue(p.Pub.MatchesSub("other.x", ""), t)
+	AssertFalse(p.Pub.MatchesSub("secret.x", ""), t)
+	AssertTrue(p.Sub.MatchesSub("other.x", ""), t)
+
+	// unless the child removes the inherited >
+	child.DefaultPermissions.Pub.Deny.Add("!>")
+	p = child.EffectivePermissions(parent)
+	AssertEquals(1, len(p.Pub.Allow), t)
+	AssertTrue(p.Pub.MatchesSub("child.x", ""), t)
+	AssertFalse(p.Pub.MatchesSub("other.x", ""), t)
+
+	child.DefaultPermissions.Pub.Allow = nil
+	p = child.EffectivePermissions(parent)
+	AssertFalse(p.Pub.MatchesSub("other.x", ""), t)
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright 2023 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwt
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrAccountNotFound is returned by a resolver fetch function for unknown accounts
+var ErrAccountNotFound = errors.New("account not found")
+
+type resolverEntry struct {
+	claims  *AccountClaims
+	err     error
+	expires time.Time
+}
+
+// ResolverCache caches the account claims returned by a fetch function. Found
+// accounts are cached for the ttl, but not past their expiration, and accounts
+// the fetch function reports as ErrAccountNotFound are cached for the negative
+// ttl. Other errors are not cached. ResolverCache is safe for concurrent use.
+type ResolverCache struct {
+	mu          sync.Mutex
+	fetch       func(pk string) (string, error)
+	ttl         time.Duration
+	negativeTTL time.Duration
+	entries     map[string]*resolverEntry
+}
+
+// NewResolverCache creates a ResolverCache for a fetch function returning the
+// account JWT for a public key
+func NewResolverCache(fetch func(pk string) (string, error), ttl time.Duration, negativeTTL time.Duration) *ResolverCache {
+	return &ResolverCache{
+		fetch:       fetch,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		entries:     make(map[string]*resolverEntry),
+	}
+}
+
+// Get returns the account claims for the public key, fetching and decoding
+// them if they are not cached
+func (rc *ResolverCache) Get(pk string) (*AccountClaims, error) {
+	now := time.Now()
+	rc.mu.Lock()
+	if e, ok := rc.entries[pk]; ok {
+		if now.Before(e.expires) {
+			rc.mu.Unlock()
+			return e.claims, e.err
+		}
+		delete(rc.entries, pk)
+	}
+	rc.mu.Unlock()
+
+	claims, err := rc.resolve(pk)
+	var entry *resolverEntry
+	switch {
+	case err == nil:
+		entry = &resolverEntry{claims: claims, expires: now.Add(rc.ttl)}
+		if claims.Expires > 0 {
+			if exp := time.Unix(claims.Expires, 0); exp.Before(entry.expires) {
+				entry.expires = exp
+			}
+		}
+	case errors.Is(err, ErrAccountNotFound):
+		entry = &resolverEntry{err: err, expires: now.Add(rc.negativeTTL)}
+	}
+	if entry != nil {
+		rc.mu.Lock()
+		rc.entries[pk] = entry
+		rc.mu.Unlock()
+	}
+	return claims, err
+}
+
+func (rc *ResolverCache) resolve(pk string) (*AccountClaims, error) {
+	token, err := rc.fetch(pk)
+	if err != nil {
+		return nil, err
+	}
+	ac, err := DecodeAccountClaims(token)
+	if err != nil {
+		return nil, err
+	}
+	if ac.Subject != pk {
+		return nil, fmt.Errorf("resolved account %q doesn't match %q", ac.Subject, pk)
+	}
+	return ac, nil
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright 2023 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwt
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResolverCache(t *testing.T) {
+	okp := createOperatorNKey(t)
+	apk := publicKey(createAccountNKey(t), t)
+	token := encode(NewAccountClaims(apk), okp, t)
+	unknown := publicKey(createAccountNKey(t), t)
+	broken := publicKey(createAccountNKey(t), t)
+
+	var fetches int32
+	fetch := func(pk string) (string, error) {
+		atomic.AddInt32(&fetches, 1)
+		switch pk {
+		case apk:
+			return token, nil
+		case broken:
+			return "", errors.New("resolver unavailable")
+		}
+		return "", fmt.Errorf("%s: %w", pk, ErrAccountNotFound)
+	}
+
+	rc := NewResolverCache(fetch, time.Hour, time.Hour)
+	for i := 0; i < 3; i++ {
+		ac, err := rc.Get(apk)
+		AssertNoError(err, t)
+		AssertEquals(apk, ac.Subject, t)
+	}
+	AssertEquals(int32(1), atomic.LoadInt32(&fetches), t)
+
+	// misses are cached for the negative ttl
+	for i := 0; i < 3; i++ {
+		_, err := rc.Get(unknown)
+		AssertTrue(errors.Is(err, ErrAccountNotFound), t)
+	}
+	AssertEquals(int32(2), atomic.LoadInt32(&fetches), t)
+
+	// other errors are not cached
+	for i := 0; i < 2; i++ {
+		_, err := rc.Get(broken)
+		AssertTrue(err != nil && !errors.Is(err, ErrAccountNotFound), t)
+	}
+	AssertEquals(int32(4), atomic.LoadInt32(&fetches), t)
+
+	// once the negative ttl passed the account is fetched again
+	rc = NewResolverCache(fetch, time.Hour, 10*time.Millisecond)
+	_, err := rc.Get(unknown)
+	AssertTrue(errors.Is(err, ErrAccountNotFound), t)
+	time.Sleep(20 * time.Millisecond)
+	_, err = rc.Get(unknown)
+	AssertTrue(errors.Is(err, ErrAccountNotFound), t)
+	AssertEquals(int32(6), atomic.LoadInt32(&fetches), t)
+}
+
+func TestResolverCacheSubjectMismatch(t *testing.T) {
+	token := encode(NewAccountClaims(publicKey(createAccountNKey(t), t)), createOperatorNKey(t), t)
+	rc := NewResolverCache(func(string) (string, error) { return token, nil }, time.Hour, time.Hour)
+	_, err := rc.Get(publicKey(createAccountNKey(t), t))
+	AssertTrue(err != nil, t)
+}
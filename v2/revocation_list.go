@@ -16,7 +16,17 @@
 package jwt
 
 import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/nats-io/nkeys"
 )
 
 const All = "*"
@@ -82,3 +92,164 @@ func (r RevocationList) allRevoked(timestamp time.Time) bool {
 	ts, ok := r[All]
 	return ok && ts >= timestamp.Unix()
 }
+
+// ParseRevocationsCSV reads a revocation list from CSV records of the form
+// public-key,revoked-before[,reason]. The revoked-before column is either a
+// unix timestamp or a RFC3339 time. An optional header row, naming the first
+// column public-key or jwt-id, is skipped. RevocationList has no place for the reasons, they are returned
+// separately, keyed by public key. Entries without a reason are not included.
+func ParseRevocationsCSV(r io.Reader) (RevocationList, map[string]string, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	rl := RevocationList{}
+	reasons := make(map[string]string)
+	for i, rec := range records {
+		if i == 0 && len(rec) > 0 && isRevocationsCSVHeader(rec[0]) {
+			continue
+		}
+		if len(rec) < 2 || len(rec) > 3 {
+			return nil, nil, fmt.Errorf("line %d: expected 2 or 3 columns, got %d", i+1, len(rec))
+		}
+		pk := strings.TrimSpace(rec[0])
+		if pk != All && !nkeys.IsValidPublicKey(pk) {
+			return nil, nil, fmt.Errorf("line %d: %q is not a public key", i+1, pk)
+		}
+		ts, err := parseRevocationTime(strings.TrimSpace(rec[1]))
+		if err != nil {
+			return nil, nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		rl.Revoke(pk, ts)
+		if len(rec) == 3 && rec[2] != "" {
+			reasons[pk] = rec[2]
+		} else {
+			delete(reasons, pk)
+		}
+	}
+	return rl, reasons, nil
+}
+
+// WriteRevocationsCSV writes the revocation list as CSV with a header row,
+// sorted by public key. Times are written as RFC3339, the reason column holds
+// the entry's reason from reasons, which can be nil.
+func WriteRevocationsCSV(w io.Writer, rl RevocationList, reasons map[string]string) error {
+	keys := make([]string, 0, len(rl))
+	for k := range rl {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"public-key", "revoked-before", "reason"}); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		ts := time.Unix(rl[k], 0).UTC().Format(time.RFC3339)
+		if err := cw.Write([]string{k, ts, reasons[k]}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func isRevocationsCSVHeader(s string) bool {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "public-key", "jwt-id":
+		return true
+	}
+	return false
+}
+
+func parseRevocationTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, errors.New("revoked-before is empty")
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(n, 0), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid revoked-before %q", s)
+	}
+	return t, nil
+}
+
+// bloomBitsPerKey and bloomHashes give a false positive rate of about 1%
+const (
+	bloomBitsPerKey = 10
+	bloomHashes     = 7
+)
+
+// RevocationBloom is a bloom filter over the keys of a RevocationList. It answers
+// most checks for keys that are not revoked without consulting the list, and falls
+// back to the list if a key may be revoked. The filter is a snapshot, it needs to
+// be rebuilt when the list changes.
+type RevocationBloom struct {
+	bits   []uint64
+	seed   maphash.Seed
+	list   RevocationList
+	all    int64
+	hasAll bool
+}
+
+// BloomFilter builds a RevocationBloom for the revocation list
+func (r RevocationList) BloomFilter() *RevocationBloom {
+	// the number of bits is a power of two, so that positions can be masked
+	n := 64
+	for n < len(r)*bloomBitsPerKey {
+		n <<= 1
+	}
+	b := &RevocationBloom{bits: make([]uint64, n/64), seed: maphash.MakeSeed(), list: r}
+	for k := range r {
+		b.add(k)
+	}
+	b.all, b.hasAll = r[All]
+	return b
+}
+
+// hash returns the two halves of the seeded hash of the key, used for double hashing
+func (b *RevocationBloom) hash(key string) (uint64, uint64) {
+	var h maphash.Hash
+	h.SetSeed(b.seed)
+	h.WriteString(key)
+	sum := h.Sum64()
+	return sum & 0xffffffff, sum>>32 | 1
+}
+
+func (b *RevocationBloom) add(key string) {
+	h1, h2 := b.hash(key)
+	mask := uint64(len(b.bits)*64 - 1)
+	for i := uint64(0); i < bloomHashes; i++ {
+		p := (h1 + i*h2) & mask
+		b.bits[p/64] |= 1 << (p % 64)
+	}
+}
+
+// MightContain returns false if the public key is definitely not in the list
+func (b *RevocationBloom) MightContain(pubKey string) bool {
+	h1, h2 := b.hash(pubKey)
+	mask := uint64(len(b.bits)*64 - 1)
+	for i := uint64(0); i < bloomHashes; i++ {
+		p := (h1 + i*h2) & mask
+		if b.bits[p/64]&(1<<(p%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// IsRevoked is like RevocationList.IsRevoked, but only consults the list if the
+// filter reports the public key as possibly revoked
+func (b *RevocationBloom) IsRevoked(pubKey string, timestamp time.Time) bool {
+	if b.hasAll && b.all >= timestamp.Unix() {
+		return true
+	}
+	if !b.MightContain(pubKey) {
+		return false
+	}
+	return b.list.IsRevoked(pubKey, timestamp)
+}
@@ -16,7 +16,10 @@
 package jwt
 
 import (
+	"bytes"
+	"fmt"
 	"sort"
+	"strings"
 	"testing"
 	"time"
 )
@@ -52,3 +55,131 @@ func TestRevocationCompact(t *testing.T) {
 		t.Error("didn't revoke expected entries")
 	}
 }
+
+func TestRevocationsCSV(t *testing.T) {
+	u1 := publicKey(createUserNKey(t), t)
+	u2 := publicKey(createUserNKey(t), t)
+	now := time.Now().UTC().Truncate(time.Second)
+
+	in := fmt.Sprintf("public-key,revoked-before,reason\n"+
+		"%s,%d,rotated\n"+
+		"%s,%s,\"leaked, see ticket 42\"\n"+
+		"*,%d\n", u1, now.Unix(), u2, now.Add(-time.Hour).Format(time.RFC3339), now.Add(-time.Minute).Unix())
+	rl, reasons, err := ParseRevocationsCSV(strings.NewReader(in))
+	AssertNoError(err, t)
+	AssertEquals(3, len(rl), t)
+	AssertEquals(now.Unix(), rl[u1], t)
+	AssertEquals(now.Add(-time.Hour).Unix(), rl[u2], t)
+	AssertEquals(now.Add(-time.Minute).Unix(), rl[All], t)
+	AssertEquals(2, len(reasons), t)
+	AssertEquals("rotated", reasons[u1], t)
+	AssertEquals("leaked, see ticket 42", reasons[u2], t)
+
+	var buf bytes.Buffer
+	AssertNoError(WriteRevocationsCSV(&buf, rl, reasons), t)
+	AssertTrue(strings.Contains(buf.String(), `"leaked, see ticket 42"`), t)
+	rl2, reasons2, err := ParseRevocationsCSV(&buf)
+	AssertNoError(err, t)
+	AssertEquals(len(rl), len(rl2), t)
+	for k, v := range rl {
+		AssertEquals(v, rl2[k], t)
+	}
+	AssertEquals(len(reasons), len(reasons2), t)
+	for k, v := range reasons {
+		AssertEquals(v, reasons2[k], t)
+	}
+
+	buf.Reset()
+	AssertNoError(WriteRevocationsCSV(&buf, rl, nil), t)
+	_, reasons2, err = ParseRevocationsCSV(&buf)
+	AssertNoError(err, t)
+	AssertEquals(0, len(reasons2), t)
+}
+
+func TestRevocationsCSVHeaderAlias(t *testing.T) {
+	u := publicKey(createUserNKey(t), t)
+	rl, reasons, err := ParseRevocationsCSV(strings.NewReader("jwt-id,revoked-before,reason\n" + u + ",1,rotated\n"))
+	AssertNoError(err, t)
+	AssertEquals(1, len(rl), t)
+	AssertEquals(int64(1), rl[u], t)
+	AssertEquals("rotated", reasons[u], t)
+}
+
+func TestRevocationsCSVErrors(t *testing.T) {
+	u := publicKey(createUserNKey(t), t)
+	for _, in := range []string{
+		"not-a-key,1\n",
+		u + ",yesterday\n",
+		u + "\n",
+		u + ",1,reason,extra\n",
+		u + ",\"1\n",
+	} {
+		if _, _, err := ParseRevocationsCSV(strings.NewReader(in)); err == nil {
+			t.Fatalf("expected %q to fail", in)
+		}
+	}
+}
+
+func TestRevocationBloom(t *testing.T) {
+	now := time.Now()
+	r := RevocationList{}
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = publicKey(createUserNKey(t), t)
+		r.Revoke(keys[i], now)
+	}
+	b := r.BloomFilter()
+	// no false negatives
+	for _, k := range keys {
+		AssertTrue(b.MightContain(k), t)
+		AssertTrue(b.IsRevoked(k, now), t)
+		AssertFalse(b.IsRevoked(k, now.Add(time.Hour)), t)
+	}
+	falsePositives := 0
+	for i := 0; i < 1000; i++ {
+		k := publicKey(createUserNKey(t), t)
+		if b.MightContain(k) {
+			falsePositives++
+		}
+		AssertFalse(b.IsRevoked(k, now), t)
+	}
+	AssertTrue(falsePositives < 50, t)
+
+	r.Revoke(All, now)
+	b = r.BloomFilter()
+	AssertTrue(b.IsRevoked(publicKey(createUserNKey(t), t), now), t)
+
+	AssertFalse(RevocationList{}.BloomFilter().IsRevoked(keys[0], now), t)
+}
+
+func benchmarkRevocations(n int) (RevocationList, []string) {
+	r := RevocationList{}
+	now := time.Now()
+	for i := 0; i < n; i++ {
+		r.Revoke(fmt.Sprintf("U%055d", i), now)
+	}
+	lookups := make([]string, 1024)
+	for i := range lookups {
+		lookups[i] = fmt.Sprintf("U%055d", n+i)
+	}
+	return r, lookups
+}
+
+func BenchmarkRevocationListIsRevoked(b *testing.B) {
+	r, lookups := benchmarkRevocations(100000)
+	now := time.Now()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.IsRevoked(lookups[i%len(lookups)], now)
+	}
+}
+
+func BenchmarkRevocationBloomIsRevoked(b *testing.B) {
+	r, lookups := benchmarkRevocations(100000)
+	bloom := r.BloomFilter()
+	now := time.Now()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bloom.IsRevoked(lookups[i%len(lookups)], now)
+	}
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright 2023 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwt
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nats-io/nkeys"
+)
+
+// SelfTest generates operator, account, signing and user keys, mints and
+// verifies a token of each type, including a user issued by a signing key,
+// and checks that a tampered token fails verification. It returns an error
+// describing the first check that fails.
+func SelfTest() error {
+	okp, err := nkeys.CreateOperator()
+	if err != nil {
+		return fmt.Errorf("self test: creating operator key: %v", err)
+	}
+	akp, err := nkeys.CreateAccount()
+	if err != nil {
+		return fmt.Errorf("self test: creating account key: %v", err)
+	}
+	skp, err := nkeys.CreateAccount()
+	if err != nil {
+		return fmt.Errorf("self test: creating signing key: %v", err)
+	}
+	ikp, err := nkeys.CreateAccount()
+	if err != nil {
+		return fmt.Errorf("self test: creating importing account key: %v", err)
+	}
+	ukp, err := nkeys.CreateUser()
+	if err != nil {
+		return fmt.Errorf("self test: creating user key: %v", err)
+	}
+	opk, _ := okp.PublicKey()
+	apk, _ := akp.PublicKey()
+	spk, _ := skp.PublicKey()
+	ipk, _ := ikp.PublicKey()
+	upk, _ := ukp.PublicKey()
+
+	oc := NewOperatorClaims(opk)
+	if err := selfTestRoundTrip("operator", oc, okp, opk); err != nil {
+		return err
+	}
+
+	ac := NewAccountClaims(apk)
+	ac.SigningKeys.Add(spk)
+	if err := selfTestRoundTrip("account", ac, okp, opk); err != nil {
+		return err
+	}
+
+	act := NewActivationClaims(ipk)
+	act.ImportSubject = "self.test"
+	act.ImportType = Stream
+	if err := selfTestRoundTrip("activation", act, akp, apk); err != nil {
+		return err
+	}
+
+	uc := NewUserClaims(upk)
+	uc.IssuerAccount = apk
+	if err := selfTestRoundTrip("user", uc, skp, spk); err != nil {
+		return err
+	}
+	if !ac.DidSign(uc) {
+		return fmt.Errorf("self test: user issued by signing key not accepted by account")
+	}
+
+	token, err := uc.Encode(skp)
+	if err != nil {
+		return fmt.Errorf("self test: encoding user: %v", err)
+	}
+	chunks := strings.Split(token, ".")
+	payload, err := decodeString(chunks[1])
+	if err != nil {
+		return fmt.Errorf("self test: decoding user payload: %v", err)
+	}
+	tampered := strings.Replace(string(payload), upk, opk, 1)
+	chunks[1] = encodeToString([]byte(tampered))
+	if _, err := Decode(strings.Join(chunks, ".")); err == nil {
+		return fmt.Errorf("self test: tampered user token passed verification")
+	}
+	return nil
+}
+
+func selfTestRoundTrip(kind string, c Claims, kp nkeys.KeyPair, issuer string) error {
+	token, err := c.Encode(kp)
+	if err != nil {
+		return fmt.Errorf("self test: encoding %s: %v", kind, err)
+	}
+	d, err := Decode(token)
+	if err != nil {
+		return fmt.Errorf("self test: decoding %s: %v", kind, err)
+	}
+	if d.Claims().Issuer != issuer {
+		return fmt.Errorf("self test: %s issuer is %q, expected %q", kind, d.Claims().Issuer, issuer)
+	}
+	if d.Claims().Subject != c.Claims().Subject {
+		return fmt.Errorf("self test: %s subject is %q, expected %q", kind, d.Claims().Subject, c.Claims().Subject)
+	}
+	if d.ClaimType() != c.ClaimType() {
+		return fmt.Errorf("self test: %s decoded as %s", kind, d.ClaimType())
+	}
+	vr := CreateValidationResults()
+	d.Validate(vr)
+	if vr.IsBlocking(false) {
+		return fmt.Errorf("self test: %s is not valid: %v", kind, vr.Errors())
+	}
+	return nil
+}
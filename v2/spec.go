@@ -0,0 +1,93 @@
+/*
+ * Copyright 2023 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwt
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nkeys"
+)
+
+// ClaimSpec is a declarative description of a user or account claim.
+// FromSpec turns it into the matching typed claim.
+type ClaimSpec struct {
+	Type    ClaimType `json:"type"`
+	Subject string    `json:"subject"`
+	Name    string    `json:"name,omitempty"`
+	// IssuerAccount is the account a user is issued for when it is signed by a signing key
+	IssuerAccount string      `json:"issuer_account,omitempty"`
+	Permissions   Permissions `json:"permissions,omitempty"`
+	// Limits applies to user claims
+	Limits *Limits `json:"limits,omitempty"`
+	// AccountLimits applies to account claims
+	AccountLimits *OperatorLimits `json:"account_limits,omitempty"`
+	// Expiry is the lifetime of the claim, zero keeps the default expiry for the type
+	Expiry time.Duration `json:"expiry,omitempty"`
+}
+
+// FromSpec creates a user or account claim from the spec. For accounts the
+// permissions become the default permissions of the account users.
+// The claim is validated before it is returned.
+func FromSpec(spec ClaimSpec) (Claims, error) {
+	if spec.Expiry < 0 {
+		return nil, errors.New("expiry can't be negative")
+	}
+	var claim Claims
+	switch spec.Type {
+	case UserClaim:
+		if !nkeys.IsValidPublicUserKey(spec.Subject) {
+			return nil, fmt.Errorf("subject %q is not a user public key", spec.Subject)
+		}
+		uc := NewUserClaims(spec.Subject)
+		uc.IssuerAccount = spec.IssuerAccount
+		uc.Permissions = spec.Permissions
+		if spec.Limits != nil {
+			uc.Limits = *spec.Limits
+		}
+		claim = uc
+	case AccountClaim:
+		if !nkeys.IsValidPublicAccountKey(spec.Subject) {
+			return nil, fmt.Errorf("subject %q is not an account public key", spec.Subject)
+		}
+		if spec.IssuerAccount != "" {
+			return nil, errors.New("issuer account is only valid for user claims")
+		}
+		ac := NewAccountClaims(spec.Subject)
+		ac.DefaultPermissions = spec.Permissions
+		if spec.AccountLimits != nil {
+			ac.Limits = *spec.AccountLimits
+		}
+		claim = ac
+	default:
+		return nil, fmt.Errorf("unsupported claim type %q", spec.Type)
+	}
+	cd := claim.Claims()
+	cd.Name = spec.Name
+	if spec.Expiry > 0 {
+		cd.Expires = time.Now().Add(spec.Expiry).UTC().Unix()
+	}
+
+	vr := CreateValidationResults()
+	claim.Validate(vr)
+	for _, i := range vr.Issues {
+		if i.Blocking || i.TimeCheck {
+			return nil, errors.New(i.Description)
+		}
+	}
+	return claim, nil
+}
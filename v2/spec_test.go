@@ -0,0 +1,83 @@
+/*
+ * Copyright 2023 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFromSpecUser(t *testing.T) {
+	upk := publicKey(createUserNKey(t), t)
+	apk := publicKey(createAccountNKey(t), t)
+	spec := ClaimSpec{
+		Type:          UserClaim,
+		Subject:       upk,
+		Name:          "worker",
+		IssuerAccount: apk,
+		Limits:        &Limits{NatsLimits: NatsLimits{Subs: 10, Data: NoLimit, Payload: NoLimit}},
+		Expiry:        time.Hour,
+	}
+	spec.Permissions.Pub.Allow.Add("orders.>")
+
+	c, err := FromSpec(spec)
+	AssertNoError(err, t)
+	uc, ok := c.(*UserClaims)
+	AssertTrue(ok, t)
+	AssertEquals(upk, uc.Subject, t)
+	AssertEquals("worker", uc.Name, t)
+	AssertEquals(apk, uc.IssuerAccount, t)
+	AssertTrue(uc.Pub.Allow.Contains("orders.>"), t)
+	AssertEquals(int64(10), uc.Subs, t)
+	AssertTrue(uc.Expires > time.Now().Unix() && uc.Expires <= time.Now().Add(time.Hour).Unix(), t)
+
+	// the claim can be encoded
+	token, err := c.Encode(createAccountNKey(t))
+	AssertNoError(err, t)
+	_, err = DecodeUserClaims(token)
+	AssertNoError(err, t)
+}
+
+func TestFromSpecAccount(t *testing.T) {
+	apk := publicKey(createAccountNKey(t), t)
+	spec := ClaimSpec{Type: AccountClaim, Subject: apk}
+	spec.Permissions.Sub.Deny.Add("secret.>")
+	c, err := FromSpec(spec)
+	AssertNoError(err, t)
+	ac, ok := c.(*AccountClaims)
+	AssertTrue(ok, t)
+	AssertTrue(ac.DefaultPermissions.Sub.Deny.Contains("secret.>"), t)
+	AssertTrue(ac.Expires > 0 && ac.Expires <= defaultExpires(AccountClaim), t)
+}
+
+func TestFromSpecInvalid(t *testing.T) {
+	apk := publicKey(createAccountNKey(t), t)
+	upk := publicKey(createUserNKey(t), t)
+	specs := []ClaimSpec{
+		{Type: UserClaim, Subject: "bad"},
+		{Type: UserClaim, Subject: apk},
+		{Type: AccountClaim, Subject: upk},
+		{Type: AccountClaim, Subject: apk, IssuerAccount: apk},
+		{Type: UserClaim, Subject: upk, IssuerAccount: upk},
+		{Type: UserClaim, Subject: upk, Expiry: -time.Hour},
+		{Type: OperatorClaim, Subject: publicKey(createOperatorNKey(t), t)},
+	}
+	for i, spec := range specs {
+		if _, err := FromSpec(spec); err == nil {
+			t.Fatalf("expected spec %d to fail", i)
+		}
+	}
+}
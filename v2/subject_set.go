@@ -0,0 +1,305 @@
+/*
+ * Copyright 2023 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwt
+
+import "sort"
+
+// tokenSet is the set of values a single subject token can take. It is either
+// a literal or any token except the ones in except.
+type tokenSet struct {
+	literal string
+	any     bool
+	except  []string
+}
+
+func (t tokenSet) excludes(l string) bool {
+	for _, e := range t.except {
+		if e == l {
+			return true
+		}
+	}
+	return false
+}
+
+func (t tokenSet) matches(l string) bool {
+	if t.any {
+		return !t.excludes(l)
+	}
+	return t.literal == l
+}
+
+func (t tokenSet) without(l string) tokenSet {
+	if t.excludes(l) {
+		return t
+	}
+	except := append(append([]string{}, t.except...), l)
+	sort.Strings(except)
+	return tokenSet{any: true, except: except}
+}
+
+func intersectTokens(a, b tokenSet) (tokenSet, bool) {
+	switch {
+	case !a.any:
+		return a, b.matches(a.literal)
+	case !b.any:
+		return b, a.matches(b.literal)
+	}
+	r := a
+	for _, e := range b.except {
+		r = r.without(e)
+	}
+	return r, true
+}
+
+// subtractTokens returns the token sets that together hold the values of a not in b
+func subtractTokens(a, b tokenSet) []tokenSet {
+	switch {
+	case !a.any:
+		if b.matches(a.literal) {
+			return nil
+		}
+		return []tokenSet{a}
+	case !b.any:
+		if a.excludes(b.literal) {
+			return []tokenSet{a}
+		}
+		return []tokenSet{a.without(b.literal)}
+	}
+	var r []tokenSet
+	for _, e := range b.except {
+		if !a.excludes(e) {
+			r = append(r, tokenSet{literal: e})
+		}
+	}
+	return r
+}
+
+// subjectPiece is the set of subjects matching its tokens. With tail set the
+// subjects have one or more additional tokens of any value.
+type subjectPiece struct {
+	tokens []tokenSet
+	tail   bool
+}
+
+func (p subjectPiece) pad(n int, tail bool) subjectPiece {
+	tokens := append([]tokenSet{}, p.tokens...)
+	for len(tokens) < n {
+		tokens = append(tokens, tokenSet{any: true})
+	}
+	return subjectPiece{tokens: tokens, tail: tail}
+}
+
+// expand splits a piece with a tail shorter than n tokens into pieces with
+// an exact number of tokens up to n, and one with n tokens and a tail
+func (p subjectPiece) expand(n int) []subjectPiece {
+	if !p.tail || len(p.tokens) >= n {
+		return []subjectPiece{p}
+	}
+	var r []subjectPiece
+	for k := len(p.tokens) + 1; k <= n; k++ {
+		r = append(r, p.pad(k, false))
+	}
+	return append(r, p.pad(n, true))
+}
+
+func (p subjectPiece) String() string {
+	s := ""
+	for i, t := range p.tokens {
+		if i > 0 {
+			s += "."
+		}
+		if t.any {
+			s += "*"
+		} else {
+			s += t.literal
+		}
+	}
+	if p.tail {
+		if s != "" {
+			s += "."
+		}
+		s += ">"
+	}
+	return s
+}
+
+func intersectPieces(a, b subjectPiece) []subjectPiece {
+	n := len(a.tokens)
+	if len(b.tokens) > n {
+		n = len(b.tokens)
+	}
+	var r []subjectPiece
+	for _, x := range a.expand(n) {
+	next:
+		for _, y := range b.expand(n) {
+			if len(x.tokens) != len(y.tokens) || x.tail != y.tail {
+				continue
+			}
+			tokens := make([]tokenSet, len(x.tokens))
+			for i := range x.tokens {
+				t, ok := intersectTokens(x.tokens[i], y.tokens[i])
+				if !ok {
+					continue next
+				}
+				tokens[i] = t
+			}
+			r = append(r, subjectPiece{tokens: tokens, tail: x.tail})
+		}
+	}
+	return r
+}
+
+// subtractPieces returns disjoint pieces holding the subjects of a not in b
+func subtractPieces(a, b subjectPiece) []subjectPiece {
+	if len(intersectPieces(a, b)) == 0 {
+		// keep disjoint pieces whole rather than splitting them up
+		return []subjectPiece{a}
+	}
+	var r []subjectPiece
+	for _, x := range a.expand(len(b.tokens)) {
+		r = append(r, subtractAligned(x, b)...)
+	}
+	return r
+}
+
+// subtractAligned subtracts b from x, which is at least as long as b unless it has no tail
+func subtractAligned(x, b subjectPiece) []subjectPiece {
+	nx, nb := len(x.tokens), len(b.tokens)
+	if b.tail {
+		if nx < nb || (nx == nb && !x.tail) {
+			return []subjectPiece{x}
+		}
+	} else if x.tail || nx != nb {
+		return []subjectPiece{x}
+	}
+	common := make([]tokenSet, nb)
+	for i := 0; i < nb; i++ {
+		t, ok := intersectTokens(x.tokens[i], b.tokens[i])
+		if !ok {
+			return []subjectPiece{x}
+		}
+		common[i] = t
+	}
+	// the subjects matching b up to token i, but not at token i
+	var r []subjectPiece
+	for i := 0; i < nb; i++ {
+		for _, d := range subtractTokens(x.tokens[i], b.tokens[i]) {
+			tokens := append([]tokenSet{}, common[:i]...)
+			tokens = append(tokens, d)
+			tokens = append(tokens, x.tokens[i+1:]...)
+			r = append(r, subjectPiece{tokens: tokens, tail: x.tail})
+		}
+	}
+	return r
+}
+
+// SubjectSet is a set of subjects built from subject patterns with wildcards.
+// Union, intersection and difference are exact, even where the result can't be
+// described by subject patterns alone. The zero value is the empty set.
+type SubjectSet struct {
+	pieces []subjectPiece
+}
+
+// NewSubjectSet returns the set of subjects matching any of the patterns
+func NewSubjectSet(patterns ...string) (SubjectSet, error) {
+	var s SubjectSet
+	for _, p := range patterns {
+		tokens, _, tail, err := ParseSubject(p)
+		if err != nil {
+			return SubjectSet{}, err
+		}
+		if tail {
+			tokens = tokens[:len(tokens)-1]
+		}
+		piece := subjectPiece{tail: tail}
+		for _, t := range tokens {
+			if t == "*" {
+				piece.tokens = append(piece.tokens, tokenSet{any: true})
+			} else {
+				piece.tokens = append(piece.tokens, tokenSet{literal: t})
+			}
+		}
+		s = s.Union(SubjectSet{pieces: []subjectPiece{piece}})
+	}
+	return s, nil
+}
+
+// Union returns the subjects in either set
+func (s SubjectSet) Union(o SubjectSet) SubjectSet {
+	pieces := append([]subjectPiece{}, s.pieces...)
+	return SubjectSet{pieces: append(pieces, o.Subtract(s).pieces...)}
+}
+
+// Intersect returns the subjects in both sets
+func (s SubjectSet) Intersect(o SubjectSet) SubjectSet {
+	var r SubjectSet
+	for _, a := range s.pieces {
+		for _, b := range o.pieces {
+			r.pieces = append(r.pieces, intersectPieces(a, b)...)
+		}
+	}
+	return r
+}
+
+// Subtract returns the subjects in s that are not in o
+func (s SubjectSet) Subtract(o SubjectSet) SubjectSet {
+	pieces := s.pieces
+	for _, b := range o.pieces {
+		var next []subjectPiece
+		for _, a := range pieces {
+			next = append(next, subtractPieces(a, b)...)
+		}
+		pieces = next
+	}
+	return SubjectSet{pieces: pieces}
+}
+
+// IsEmpty returns true if the set holds no subjects
+func (s SubjectSet) IsEmpty() bool {
+	return len(s.pieces) == 0
+}
+
+// Equal returns true if both sets hold the same subjects
+func (s SubjectSet) Equal(o SubjectSet) bool {
+	return s.Subtract(o).IsEmpty() && o.Subtract(s).IsEmpty()
+}
+
+// Overlaps returns true if a subject is in both sets
+func (s SubjectSet) Overlaps(o SubjectSet) bool {
+	return !s.Intersect(o).IsEmpty()
+}
+
+// Contains returns true if all subjects matching the pattern are in the set.
+// Invalid patterns are not contained.
+func (s SubjectSet) Contains(pattern string) bool {
+	p, err := NewSubjectSet(pattern)
+	if err != nil {
+		return false
+	}
+	return p.Subtract(s).IsEmpty()
+}
+
+// Patterns returns the sorted subject patterns describing the set. Where the
+// set excludes specific tokens the pattern uses a * for the remaining tokens,
+// so the patterns can match more subjects than the set holds.
+func (s SubjectSet) Patterns() []string {
+	var l StringList
+	for _, p := range s.pieces {
+		l.Add(p.String())
+	}
+	sort.Strings(l)
+	return l
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright 2023 The NATS Authors
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jwt
+
+import (
+	"fmt"
+	"testing"
+)
+
+var subjectSetPatterns = []string{"foo", "foo.bar", "foo.*", "foo.>", "*.bar", ">", "*", "foo.*.baz", "bar.>", "*.*.baz", "foo.bar.>"}
+
+var subjectSetSamples = []string{"foo", "bar", "foo.bar", "foo.x", "bar.bar", "x.bar", "foo.bar.baz", "foo.x.baz",
+	"x.y.baz", "bar.x.y", "foo.bar.x", "x.y.z.w"}
+
+func mustSubjectSet(t *testing.T, patterns ...string) SubjectSet {
+	t.Helper()
+	s, err := NewSubjectSet(patterns...)
+	AssertNoError(err, t)
+	return s
+}
+
+// inPatterns is the reference membership test for concrete subjects
+func inPatterns(subject string, patterns ...string) bool {
+	for _, p := range patterns {
+		if Subject(subject).IsContainedIn(Subject(p)) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSubjectSetMembership(t *testing.T) {
+	for _, a := range subjectSetPatterns {
+		for _, b := range subjectSetPatterns {
+			sa, sb := mustSubjectSet(t, a), mustSubjectSet(t, b)
+			union, inter, diff := sa.Union(sb), sa.Intersect(sb), sa.Subtract(sb)
+			for _, subj := range subjectSetSamples {
+				inA, inB := inPatterns(subj, a), inPatterns(subj, b)
+				msg := fmt.Sprintf("%q with %q and %q", subj, a, b)
+				if union.Contains(subj) != (inA || inB) {
+					t.Fatalf("union membership of %s", msg)
+				}
+				if inter.Contains(subj) != (inA && inB) {
+					t.Fatalf("intersection membership of %s", msg)
+				}
+				if diff.Contains(subj) != (inA && !inB) {
+					t.Fatalf("difference membership of %s", msg)
+				}
+			}
+		}
+	}
+}
+
+func TestSubjectSetLaws(t *testing.T) {
+	for _, a := range subjectSetPatterns {
+		sa := mustSubjectSet(t, a)
+		AssertTrue(sa.Subtract(sa).IsEmpty(), t)
+		AssertTrue(sa.Union(sa).Equal(sa), t)
+		AssertTrue(sa.Intersect(sa).Equal(sa), t)
+		AssertTrue(sa.Union(SubjectSet{}).Equal(sa), t)
+		AssertTrue(sa.Intersect(SubjectSet{}).IsEmpty(), t)
+		AssertTrue(sa.Contains(a), t)
+		for _, b := range subjectSetPatterns {
+			sb := mustSubjectSet(t, b)
+			msg := fmt.Sprintf("%q and %q", a, b)
+			check := func(law string, ok bool) {
+				if !ok {
+					t.Fatalf("%s doesn't hold for %s", law, msg)
+				}
+			}
+			check("union commutativity", sa.Union(sb).Equal(sb.Union(sa)))
+			check("intersection commutativity", sa.Intersect(sb).Equal(sb.Intersect(sa)))
+			check("absorption", sa.Union(sa.Intersect(sb)).Equal(sa))
+			check("difference", sa.Subtract(sb).Union(sa.Intersect(sb)).Equal(sa))
+			check("disjoint difference", sa.Subtract(sb).Intersect(sb).IsEmpty())
+			check("overlap", sa.Overlaps(sb) == !sa.Intersect(sb).IsEmpty())
+			check("containment", sa.Contains(b) == sb.Subtract(sa).IsEmpty())
+			check("pattern containment", !Subject(b).IsContainedIn(Subject(a)) || sa.Contains(b) ||
+				// IsContainedIn treats > as contained in *
+				a[len(a)-1] == '*')
+			for _, c := range subjectSetPatterns {
+				sc := mustSubjectSet(t, c)
+				msg = fmt.Sprintf("%q, %q and %q", a, b, c)
+				check("union associativity", sa.Union(sb).Union(sc).Equal(sa.Union(sb.Union(sc))))
+				check("intersection associativity", sa.Intersect(sb).Intersect(sc).Equal(sa.Intersect(sb.Intersect(sc))))
+				check("distributivity", sa.Intersect(sb.Union(sc)).Equal(sa.Intersect(sb).Union(sa.Intersect(sc))))
+				check("de morgan", sa.Subtract(sb.Union(sc)).Equal(sa.Subtract(sb).Intersect(sa.Subtract(sc))))
+			}
+		}
+	}
+}
+
+func TestSubjectSet(t *testing.T) {
+	s := mustSubjectSet(t, "orders.>").Subtract(mustSubjectSet(t, "orders.new", "orders.cancel"))
+	AssertFalse(s.Contains("orders.new"), t)
+	AssertTrue(s.Contains("orders.other"), t)
+	AssertTrue(s.Contains("orders.new.x"), t)
+	AssertFalse(s.Contains("orders.>"), t)
+	AssertTrue(s.Contains("orders.*.>"), t)
+	AssertEquals(fmt.Sprint([]string{"orders.*", "orders.*.>"}), fmt.Sprint(s.Patterns()), t)
+
+	// the subjects excluded by a wildcard can be added back
+	AssertTrue(s.Union(mustSubjectSet(t, "orders.*")).Equal(mustSubjectSet(t, "orders.>")), t)
+	AssertTrue(mustSubjectSet(t, "orders.*", "orders.*.>").Equal(mustSubjectSet(t, "orders.>")), t)
+
+	AssertFalse(s.Contains("bad..subject"), t)
+	_, err := NewSubjectSet("foo.>.bar")
+	AssertTrue(err != nil, t)
+	AssertTrue(SubjectSet{}.IsEmpty(), t)
+}
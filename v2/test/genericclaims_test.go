@@ -16,11 +16,15 @@
 package jwt
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
 	"testing"
 	"time"
 
 	jwtv1 "github.com/nats-io/jwt"
 	. "github.com/nats-io/jwt/v2"
+	"github.com/nats-io/nkeys"
 )
 
 func TestNewGenericClaims(t *testing.T) {
@@ -120,3 +124,73 @@ func TestGenericClaimsCanHaveCustomTypeFromV1(t *testing.T) {
 		t.Fatalf("expected internal type to be 'my_type': %v", gc2.Data["type"])
 	}
 }
+
+func TestGenericClaimsMetadataJSON(t *testing.T) {
+	akp := createAccountNKey(t)
+	apk := publicKey(akp, t)
+
+	gc := NewGenericClaims(apk)
+	gc.Expires = time.Now().Add(time.Hour).UTC().Unix()
+	gc.Data["type"] = "my_type"
+	gc2, err := DecodeGeneric(encode(gc, akp, t))
+	AssertNoError(err, t)
+
+	d, err := gc2.MetadataJSON()
+	AssertNoError(err, t)
+	var m map[string]interface{}
+	AssertNoError(json.Unmarshal(d, &m), t)
+	AssertEquals(apk, m["iss"], t)
+	AssertEquals(apk, m["sub"], t)
+	AssertEquals(gc2.ID, m["jti"], t)
+	AssertEquals(GenericClaim, m["type"], t)
+
+	exp, err := time.Parse(time.RFC3339, m["exp_rfc3339"].(string))
+	AssertNoError(err, t)
+	AssertEquals(int64(m["exp"].(float64)), exp.Unix(), t)
+	AssertEquals(gc.Expires, exp.Unix(), t)
+
+	// no expiration, no timestamps
+	gc.Expires = 0
+	d, err = gc.MetadataJSON()
+	AssertNoError(err, t)
+	m = nil
+	AssertNoError(json.Unmarshal(d, &m), t)
+	_, ok := m["exp_rfc3339"]
+	AssertFalse(ok, t)
+}
+
+func TestGenericClaimsVerifyPossession(t *testing.T) {
+	akp := createAccountNKey(t)
+	ckp, err := nkeys.CreateUser()
+	AssertNoError(err, t)
+
+	gc := NewGenericClaims(publicKey(createAccountNKey(t), t))
+	gc.Confirmation = publicKey(ckp, t)
+	token := encode(gc, akp, t)
+	gc2, err := DecodeGeneric(token)
+	AssertNoError(err, t)
+	AssertEquals(gc.Confirmation, gc2.Confirmation, t)
+
+	// the registered cnf claim is left alone
+	payload, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[1])
+	AssertNoError(err, t)
+	var m map[string]interface{}
+	AssertNoError(json.Unmarshal(payload, &m), t)
+	AssertEquals(gc.Confirmation, m["confirmation_key"], t)
+	_, ok := m["cnf"]
+	AssertFalse(ok, t)
+
+	challenge := []byte("challenge")
+	proof, err := ckp.Sign(challenge)
+	AssertNoError(err, t)
+	AssertNoError(gc2.VerifyPossession(proof, challenge), t)
+	AssertTrue(gc2.VerifyPossession(proof, []byte("other challenge")) != nil, t)
+
+	other, err := nkeys.CreateUser()
+	AssertNoError(err, t)
+	proof, err = other.Sign(challenge)
+	AssertNoError(err, t)
+	AssertTrue(gc2.VerifyPossession(proof, challenge) != nil, t)
+
+	AssertTrue(NewGenericClaims(gc.Subject).VerifyPossession(proof, challenge) != nil, t)
+}
@@ -17,12 +17,15 @@ package jwt
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/url"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -183,6 +186,89 @@ func (s Subject) Validate(vr *ValidationResults) {
 	}
 }
 
+// WildcardConfig holds the characters used as single token and tail wildcards.
+// The zero value uses the NATS wildcards * and >.
+type WildcardConfig struct {
+	Single byte
+	Tail   byte
+}
+
+// DefaultWildcardConfig is the NATS wildcard configuration
+var DefaultWildcardConfig = WildcardConfig{Single: '*', Tail: '>'}
+
+func (w WildcardConfig) normalize() (string, string) {
+	single, tail := w.Single, w.Tail
+	if single == 0 {
+		single = DefaultWildcardConfig.Single
+	}
+	if tail == 0 {
+		tail = DefaultWildcardConfig.Tail
+	}
+	return string(single), string(tail)
+}
+
+// ParseSubject validates and tokenizes the subject. It reports if the subject
+// contains a * wildcard token and if it ends with the > wildcard. An error is
+// returned for empty subjects or tokens, whitespace and a > that isn't last.
+func ParseSubject(s string) (tokens []string, hasSingleWildcard bool, hasTailWildcard bool, err error) {
+	return DefaultWildcardConfig.ParseSubject(s)
+}
+
+// ParseSubject is like the package level ParseSubject but uses the configured wildcards
+func (w WildcardConfig) ParseSubject(s string) (tokens []string, hasSingleWildcard bool, hasTailWildcard bool, err error) {
+	single, tail := w.normalize()
+	if s == "" {
+		return nil, false, false, errors.New("subject cannot be empty")
+	}
+	if strings.ContainsAny(s, " \t\r\n") {
+		return nil, false, false, fmt.Errorf("subject %q cannot have whitespace", s)
+	}
+	tokens = strings.Split(s, ".")
+	for i, t := range tokens {
+		switch t {
+		case "":
+			return nil, false, false, fmt.Errorf("subject %q has an empty token", s)
+		case single:
+			hasSingleWildcard = true
+		case tail:
+			if i != len(tokens)-1 {
+				return nil, false, false, fmt.Errorf("subject %q can only have %s as last token", s, tail)
+			}
+			hasTailWildcard = true
+		}
+	}
+	return tokens, hasSingleWildcard, hasTailWildcard, nil
+}
+
+// IsContainedIn is like Subject.IsContainedIn but uses the configured wildcards
+func (w WildcardConfig) IsContainedIn(subject Subject, other Subject) bool {
+	single, tail := w.normalize()
+	otherArray := strings.Split(string(other), ".")
+	myArray := strings.Split(string(subject), ".")
+
+	if len(myArray) > len(otherArray) && otherArray[len(otherArray)-1] != tail {
+		return false
+	}
+
+	if len(myArray) < len(otherArray) {
+		return false
+	}
+
+	for ind, tok := range otherArray {
+		myTok := myArray[ind]
+
+		if ind == len(otherArray)-1 && tok == tail {
+			return true
+		}
+
+		if tok != myTok && tok != single {
+			return false
+		}
+	}
+
+	return true
+}
+
 func (s Subject) countTokenWildcards() int {
 	v := string(s)
 	if v == "*" {
@@ -210,30 +296,7 @@ func (s Subject) HasWildCards() bool {
 
 // IsContainedIn does a simple test to see if the subject is contained in another subject
 func (s Subject) IsContainedIn(other Subject) bool {
-	otherArray := strings.Split(string(other), ".")
-	myArray := strings.Split(string(s), ".")
-
-	if len(myArray) > len(otherArray) && otherArray[len(otherArray)-1] != ">" {
-		return false
-	}
-
-	if len(myArray) < len(otherArray) {
-		return false
-	}
-
-	for ind, tok := range otherArray {
-		myTok := myArray[ind]
-
-		if ind == len(otherArray)-1 && tok == ">" {
-			return true
-		}
-
-		if tok != myTok && tok != "*" {
-			return false
-		}
-	}
-
-	return true
+	return DefaultWildcardConfig.IsContainedIn(s, other)
 }
 
 // TimeRange is used to represent a start and end time
@@ -280,6 +343,43 @@ func (u *UserLimits) IsUnlimited() bool {
 	return len(u.Src) == 0 && len(u.Times) == 0
 }
 
+// AllowedAt returns true if t falls in one of the time ranges, read as times
+// of day in the Locale time zone or UTC if no Locale is set. Ranges ending
+// before they start span midnight. Without time ranges all times are allowed,
+// an invalid range or Locale allows none.
+func (u *UserLimits) AllowedAt(t time.Time) bool {
+	if len(u.Times) == 0 {
+		return true
+	}
+	loc := time.UTC
+	if u.Locale != "" {
+		var err error
+		if loc, err = time.LoadLocation(u.Locale); err != nil {
+			return false
+		}
+	}
+	format := "15:04:05"
+	now, _ := time.Parse(format, t.In(loc).Format(format))
+	for _, tr := range u.Times {
+		start, err := time.Parse(format, tr.Start)
+		if err != nil {
+			continue
+		}
+		end, err := time.Parse(format, tr.End)
+		if err != nil {
+			continue
+		}
+		if start.After(end) {
+			if !now.Before(start) || now.Before(end) {
+				return true
+			}
+		} else if !now.Before(start) && now.Before(end) {
+			return true
+		}
+	}
+	return false
+}
+
 // Limits are used to control acccess for users and importing accounts
 type Limits struct {
 	UserLimits
@@ -350,6 +450,81 @@ func (p *Permission) Validate(vr *ValidationResults, permitQueue bool) {
 	}
 }
 
+// MatchStats counts how MatchesSubWithStats reached its decisions.
+// The counters are updated atomically.
+type MatchStats struct {
+	// DenyHits counts matches that stopped at a deny entry
+	DenyHits uint64
+	// AllowHits counts matches that stopped at an allow entry
+	AllowHits uint64
+	// AllowScans counts matches that scanned the full allow list without a match
+	AllowScans uint64
+}
+
+// entryAllows returns true if the permission entry matches all the subjects of
+// the subscription
+func entryAllows(entry, subject, queue string) bool {
+	tk := strings.Split(entry, " ")
+	if !patternCovers(tk[0], subject) {
+		return false
+	}
+	if len(tk) == 1 {
+		return true
+	}
+	return queue != "" && patternCovers(tk[1], queue)
+}
+
+// entryDenies returns true if the permission entry matches any of the subjects
+// of the subscription
+func entryDenies(entry, subject, queue string) bool {
+	tk := strings.Split(entry, " ")
+	if !patternsOverlap(tk[0], subject) {
+		return false
+	}
+	if len(tk) == 1 {
+		return true
+	}
+	return queue != "" && patternsOverlap(tk[1], queue)
+}
+
+// MatchesSub returns true if the subscribe permission allows subscribing to the
+// subject, in the queue group if queue isn't empty. Entries scoped to a queue
+// group only match subscriptions in that group, entries without a group match
+// any subscription. Deny entries take precedence and an empty allow list
+// permits all subjects. A wildcard subscription is only allowed by an entry
+// matching all its subjects, and denied by an entry matching any of them.
+func (p Permission) MatchesSub(subject, queue string) bool {
+	return p.MatchesSubWithStats(subject, queue, nil)
+}
+
+// MatchesSubWithStats is like MatchesSub and records how the decision was made
+// in stats, if stats is not nil
+func (p Permission) MatchesSubWithStats(subject, queue string, stats *MatchStats) bool {
+	for _, e := range p.Deny {
+		if entryDenies(e, subject, queue) {
+			if stats != nil {
+				atomic.AddUint64(&stats.DenyHits, 1)
+			}
+			return false
+		}
+	}
+	if len(p.Allow) == 0 {
+		return true
+	}
+	for _, e := range p.Allow {
+		if entryAllows(e, subject, queue) {
+			if stats != nil {
+				atomic.AddUint64(&stats.AllowHits, 1)
+			}
+			return true
+		}
+	}
+	if stats != nil {
+		atomic.AddUint64(&stats.AllowScans, 1)
+	}
+	return false
+}
+
 // ResponsePermission can be used to allow responses to any reply subject
 // that is received on a valid subscription.
 type ResponsePermission struct {
@@ -378,6 +553,22 @@ func (p *Permissions) Validate(vr *ValidationResults) {
 	p.Pub.Validate(vr, false)
 }
 
+// ValidWithExpiry validates the permissions and checks that a response permission
+// expiring from now doesn't outlive the token. A zero tokenExpiry means the token
+// doesn't expire.
+func (p Permissions) ValidWithExpiry(tokenExpiry time.Time) error {
+	vr := CreateValidationResults()
+	p.Validate(vr)
+	if vr.IsBlocking(false) {
+		return vr.Errors()[0]
+	}
+	if p.Resp != nil && p.Resp.Expires > 0 && !tokenExpiry.IsZero() &&
+		time.Now().Add(p.Resp.Expires).After(tokenExpiry) {
+		return fmt.Errorf("response permission expiry %v exceeds the token expiry", p.Resp.Expires)
+	}
+	return nil
+}
+
 // StringList is a wrapper for an array of strings
 type StringList []string
 
@@ -413,6 +604,14 @@ func (u *StringList) Remove(p ...string) {
 	}
 }
 
+// Stable returns a sorted copy of the list, the list itself keeps its insertion order
+func (u *StringList) Stable() StringList {
+	s := make(StringList, len(*u))
+	copy(s, *u)
+	sort.Strings(s)
+	return s
+}
+
 // TagList is a unique array of lower case strings
 // All tag list methods lower case the strings in the arguments
 type TagList []string
@@ -21,6 +21,7 @@ import (
 	"regexp"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestVersion(t *testing.T) {
@@ -111,6 +112,56 @@ func TestTimeRangeValidation(t *testing.T) {
 	}
 }
 
+func TestUserLimitsAllowedAt(t *testing.T) {
+	l := &Limits{UserLimits: UserLimits{
+		Times:  []TimeRange{{Start: "09:00:00", End: "17:00:00"}},
+		Locale: "America/New_York",
+	}}
+	vr := CreateValidationResults()
+	l.Validate(vr)
+	AssertTrue(vr.IsEmpty(), t)
+
+	at := func(s string) time.Time {
+		tm, err := time.Parse(time.RFC3339, s)
+		AssertNoError(err, t)
+		return tm
+	}
+	// standard time is UTC-5
+	AssertFalse(l.AllowedAt(at("2024-01-15T13:59:59Z")), t)
+	AssertTrue(l.AllowedAt(at("2024-01-15T14:00:00Z")), t)
+	AssertTrue(l.AllowedAt(at("2024-01-15T21:59:59Z")), t)
+	AssertFalse(l.AllowedAt(at("2024-01-15T22:00:00Z")), t)
+	// daylight saving time is UTC-4
+	AssertFalse(l.AllowedAt(at("2024-07-15T12:59:59Z")), t)
+	AssertTrue(l.AllowedAt(at("2024-07-15T13:00:00Z")), t)
+	AssertFalse(l.AllowedAt(at("2024-07-15T21:00:00Z")), t)
+	// the same UTC instant either side of the switch on 2024-03-10
+	AssertFalse(l.AllowedAt(at("2024-03-09T13:30:00Z")), t)
+	AssertTrue(l.AllowedAt(at("2024-03-11T13:30:00Z")), t)
+	// and back on 2024-11-03
+	AssertFalse(l.AllowedAt(at("2024-11-02T21:30:00Z")), t)
+	AssertTrue(l.AllowedAt(at("2024-11-04T21:30:00Z")), t)
+
+	// without a locale the ranges are in UTC
+	l.Locale = ""
+	AssertTrue(l.AllowedAt(at("2024-01-15T09:00:00Z")), t)
+	AssertFalse(l.AllowedAt(at("2024-01-15T17:00:00Z")), t)
+
+	// ranges can span midnight
+	l.Times = []TimeRange{{Start: "22:00:00", End: "02:00:00"}}
+	AssertTrue(l.AllowedAt(at("2024-01-15T23:00:00Z")), t)
+	AssertTrue(l.AllowedAt(at("2024-01-15T01:00:00Z")), t)
+	AssertFalse(l.AllowedAt(at("2024-01-15T12:00:00Z")), t)
+
+	l.Locale = "Mars/Olympus_Mons"
+	AssertFalse(l.AllowedAt(at("2024-01-15T23:00:00Z")), t)
+	vr = CreateValidationResults()
+	l.Validate(vr)
+	AssertFalse(vr.IsEmpty(), t)
+
+	AssertTrue((&Limits{}).AllowedAt(time.Now()), t)
+}
+
 func TestTagList(t *testing.T) {
 	tags := TagList{}
 
@@ -163,6 +214,25 @@ func TestStringList(t *testing.T) {
 	AssertEquals(true, slist.Contains("ONE"), t)
 }
 
+func TestStringListStable(t *testing.T) {
+	slist := StringList{}
+	slist.Add("c", "a", "b")
+
+	stable := slist.Stable()
+	AssertEquals(3, len(stable), t)
+	AssertEquals("a", stable[0], t)
+	AssertEquals("b", stable[1], t)
+	AssertEquals("c", stable[2], t)
+
+	// the original keeps its insertion order
+	AssertEquals("c", slist[0], t)
+	AssertEquals("a", slist[1], t)
+	AssertEquals("b", slist[2], t)
+
+	empty := StringList{}
+	AssertEquals(0, len(empty.Stable()), t)
+}
+
 func TestSubjectValid(t *testing.T) {
 	var s Subject
 
@@ -406,3 +476,146 @@ func TestInvalidInfo(t *testing.T) {
 		}
 	}
 }
+
+func TestParseSubject(t *testing.T) {
+	tokens, single, tail, err := ParseSubject("foo.*.bar.>")
+	AssertNoError(err, t)
+	AssertEquals(4, len(tokens), t)
+	AssertEquals("bar", tokens[2], t)
+	AssertTrue(single, t)
+	AssertTrue(tail, t)
+
+	tokens, single, tail, err = ParseSubject("foo.bar")
+	AssertNoError(err, t)
+	AssertEquals(2, len(tokens), t)
+	AssertFalse(single, t)
+	AssertFalse(tail, t)
+
+	for _, s := range []string{"foo.>.bar", "", "foo..bar", ".foo", "foo.", "foo bar", "foo\tbar"} {
+		if _, _, _, err := ParseSubject(s); err == nil {
+			t.Fatalf("expected %q to fail", s)
+		}
+	}
+}
+
+func TestWildcardConfig(t *testing.T) {
+	mqtt := WildcardConfig{Single: '+', Tail: '#'}
+	AssertTrue(mqtt.IsContainedIn("foo.bar.baz", "foo.+.baz"), t)
+	AssertTrue(mqtt.IsContainedIn("foo.bar.baz", "foo.#"), t)
+	AssertFalse(mqtt.IsContainedIn("foo.bar.baz", "foo.*.baz"), t)
+	AssertFalse(mqtt.IsContainedIn("foo.bar.baz", "foo.>"), t)
+
+	_, single, tail, err := mqtt.ParseSubject("foo.+.bar.#")
+	AssertNoError(err, t)
+	AssertTrue(single, t)
+	AssertTrue(tail, t)
+	_, _, _, err = mqtt.ParseSubject("foo.#.bar")
+	AssertTrue(err != nil, t)
+	_, single, tail, err = mqtt.ParseSubject("foo.*.>.bar")
+	AssertNoError(err, t)
+	AssertFalse(single, t)
+	AssertFalse(tail, t)
+
+	// the zero value and the defaults behave like NATS
+	for _, w := range []WildcardConfig{{}, DefaultWildcardConfig} {
+		AssertTrue(w.IsContainedIn("foo.bar.baz", "foo.*.baz"), t)
+		AssertTrue(w.IsContainedIn("foo.bar.baz", "foo.>"), t)
+		AssertFalse(w.IsContainedIn("foo.bar.baz", "foo.+.baz"), t)
+	}
+	AssertTrue(Subject("foo.bar").IsContainedIn("foo.>"), t)
+	AssertFalse(Subject("foo.bar").IsContainedIn("foo.#"), t)
+}
+
+func TestPermissionsValidWithExpiry(t *testing.T) {
+	var p Permissions
+	AssertNoError(p.ValidWithExpiry(time.Now().Add(time.Hour)), t)
+
+	p.Resp = &ResponsePermission{MaxMsgs: 1, Expires: time.Minute}
+	AssertNoError(p.ValidWithExpiry(time.Now().Add(time.Hour)), t)
+	AssertNoError(p.ValidWithExpiry(time.Time{}), t)
+	AssertTrue(p.ValidWithExpiry(time.Now().Add(30*time.Second)) != nil, t)
+
+	// a response permission without expiry is fine
+	p.Resp.Expires = 0
+	AssertNoError(p.ValidWithExpiry(time.Now().Add(time.Second)), t)
+
+	p.Pub.Allow.Add("bad subject")
+	AssertTrue(p.ValidWithExpiry(time.Time{}) != nil, t)
+}
+
+func TestPermissionMatchesSub(t *testing.T) {
+	var p Permission
+	p.Allow.Add("orders.* workers")
+	AssertTrue(p.MatchesSub("orders.new", "workers"), t)
+	AssertFalse(p.MatchesSub("orders.new", ""), t)
+	AssertFalse(p.MatchesSub("orders.new", "others"), t)
+	AssertFalse(p.MatchesSub("billing.new", "workers"), t)
+
+	// entries without a group match any queue
+	p.Allow.Add("billing.>")
+	AssertTrue(p.MatchesSub("billing.new", ""), t)
+	AssertTrue(p.MatchesSub("billing.new", "others"), t)
+
+	// queue groups can be wildcards
+	p.Allow.Add("audit workers.*")
+	AssertTrue(p.MatchesSub("audit", "workers.eu"), t)
+	AssertFalse(p.MatchesSub("audit", "workers"), t)
+
+	// denies restricted to a group only deny that group
+	p.Deny.Add("billing.secret spies")
+	AssertFalse(p.MatchesSub("billing.secret", "spies"), t)
+	AssertTrue(p.MatchesSub("billing.secret", ""), t)
+	p.Deny.Add("billing.admin")
+	AssertFalse(p.MatchesSub("billing.admin", "workers"), t)
+
+	AssertTrue(Permission{}.MatchesSub("anything", "q"), t)
+}
+
+func TestPermissionMatchesWildcardSub(t *testing.T) {
+	// a > subscription isn't allowed by a *
+	var p Permission
+	p.Allow.Add("orders.*", "billing.>", "audit.> workers.*")
+	AssertFalse(p.MatchesSub("orders.>", ""), t)
+	AssertTrue(p.MatchesSub("orders.*", ""), t)
+	AssertTrue(p.MatchesSub("billing.*", ""), t)
+	AssertTrue(p.MatchesSub("billing.>", ""), t)
+	AssertFalse(p.MatchesSub(">", ""), t)
+	AssertTrue(p.MatchesSub("audit.x", "workers.eu"), t)
+	AssertFalse(p.MatchesSub("audit.x", "workers.>"), t)
+
+	// a wildcard subscription is denied if a deny matches any of its subjects
+	p.Deny.Add("billing.secret", "orders.* spies.*")
+	AssertFalse(p.MatchesSub("billing.>", ""), t)
+	AssertFalse(p.MatchesSub("billing.*", ""), t)
+	AssertTrue(p.MatchesSub("billing.public.*", ""), t)
+	AssertFalse(p.MatchesSub("orders.*", "spies.>"), t)
+	AssertFalse(p.MatchesSub("orders.new", "spies.eu"), t)
+	AssertTrue(p.MatchesSub("orders.new", "workers"), t)
+
+	var d Permission
+	d.Deny.Add("orders.*")
+	AssertFalse(d.MatchesSub("orders.>", ""), t)
+	AssertFalse(d.MatchesSub(">", ""), t)
+	AssertTrue(d.MatchesSub("billing.>", ""), t)
+
+	var stats MatchStats
+	AssertFalse(p.MatchesSubWithStats("orders.>", "", &stats), t)
+	AssertEquals(MatchStats{AllowScans: 1}, stats, t)
+}
+
+func TestPermissionMatchStats(t *testing.T) {
+	var p Permission
+	p.Allow.Add("orders.*", "billing.>")
+	p.Deny.Add("orders.secret")
+
+	var stats MatchStats
+	AssertFalse(p.MatchesSubWithStats("orders.secret", "", &stats), t)
+	AssertEquals(MatchStats{DenyHits: 1}, stats, t)
+	AssertFalse(p.MatchesSubWithStats("audit", "", &stats), t)
+	AssertEquals(MatchStats{DenyHits: 1, AllowScans: 1}, stats, t)
+	AssertTrue(p.MatchesSubWithStats("billing.new", "", &stats), t)
+	AssertEquals(MatchStats{DenyHits: 1, AllowHits: 1, AllowScans: 1}, stats, t)
+
+	// no collector
+	AssertTrue(p.MatchesSubWithStats("orders.new", "", nil), t)
+}
@@ -54,6 +54,97 @@ func (u *User) Validate(vr *ValidationResults) {
 	// When BearerToken is true server will ignore any nonce-signing verification
 }
 
+const (
+	// AuthzPub is the AuthorizeTrace direction for publishing
+	AuthzPub = "pub"
+	// AuthzSub is the AuthorizeTrace direction for subscribing
+	AuthzSub = "sub"
+)
+
+const (
+	// AuthzRuleBlock is a subject blocked by the account
+	AuthzRuleBlock = "account_block"
+	// AuthzRuleDeny is a deny entry of the user
+	AuthzRuleDeny = "deny"
+	// AuthzRuleAllow is an allow entry of the user
+	AuthzRuleAllow = "allow"
+)
+
+// AuthzRule is a rule considered by AuthorizeTrace
+type AuthzRule struct {
+	Kind    string `json:"kind"`
+	Entry   string `json:"entry"`
+	Matched bool   `json:"matched"`
+}
+
+// AuthzTrace records how AuthorizeTrace reached its decision
+type AuthzTrace struct {
+	Direction string      `json:"direction"`
+	Subject   string      `json:"subject"`
+	Rules     []AuthzRule `json:"rules,omitempty"`
+	Allowed   bool        `json:"allowed"`
+	// Decision is the rule that decided, nil if the subject was allowed by an
+	// empty allow list, was blocked by several account blocks together, or the
+	// direction is unknown
+	Decision *AuthzRule `json:"decision,omitempty"`
+	// Blocked is true if an account block decided
+	Blocked bool `json:"blocked"`
+}
+
+func (t *AuthzTrace) consider(kind, entry string, matched bool) bool {
+	t.Rules = append(t.Rules, AuthzRule{Kind: kind, Entry: entry, Matched: matched})
+	if matched {
+		t.Decision = &t.Rules[len(t.Rules)-1]
+	}
+	return matched
+}
+
+// AuthorizeTrace evaluates whether the user may publish or subscribe (direction
+// is AuthzPub or AuthzSub) to the subject and returns each rule considered, in
+// order. The subjects blocked by acct are checked first, then the user's deny
+// and allow entries, using the same rules as Account.IsBlocked and
+// Permission.MatchesSub. acct may be nil. An unknown direction is not allowed.
+func (u *User) AuthorizeTrace(acct *Account, direction, subject string) AuthzTrace {
+	trace := AuthzTrace{Direction: direction, Subject: subject}
+	var p Permission
+	switch direction {
+	case AuthzPub:
+		p = u.Pub
+	case AuthzSub:
+		p = u.Sub
+	default:
+		return trace
+	}
+	if acct != nil {
+		for _, b := range acct.BlockedSubjects {
+			if trace.consider(AuthzRuleBlock, b, patternCovers(b, subject)) {
+				trace.Blocked = true
+				return trace
+			}
+		}
+		if acct.IsBlocked(subject) {
+			trace.Blocked = true
+			return trace
+		}
+	}
+	for _, e := range p.Deny {
+		if trace.consider(AuthzRuleDeny, e, entryDenies(e, subject, "")) {
+			return trace
+		}
+	}
+	if len(p.Allow) == 0 {
+		trace.Allowed = true
+		return trace
+	}
+	for _, e := range p.Allow {
+		if trace.consider(AuthzRuleAllow, e, entryAllows(e, subject, "")) {
+			trace.Allowed = true
+			return trace
+		}
+	}
+	return trace
+}
+
 // UserClaims defines a user JWT
 type UserClaims struct {
 	ClaimsData
@@ -67,6 +158,7 @@ func NewUserClaims(subject string) *UserClaims {
 	}
 	c := &UserClaims{}
 	c.Subject = subject
+	c.Expires = defaultExpires(UserClaim)
 	c.Limits = Limits{
 		UserLimits{CIDRList{}, nil, ""},
 		NatsLimits{NoLimit, NoLimit, NoLimit},
@@ -16,6 +16,7 @@
 package jwt
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -405,3 +406,146 @@ func TestUserClaimRevocation(t *testing.T) {
 		t.Fatal("account validation shouldn't have failed")
 	}
 }
+
+func TestDefaultExpiry(t *testing.T) {
+	uc := NewUserClaims(publicKey(createUserNKey(t), t))
+	oc := NewOperatorClaims(publicKey(createOperatorNKey(t), t))
+	AssertTrue(uc.Expires > 0, t)
+	AssertTrue(oc.Expires > uc.Expires, t)
+	AssertTrue(DefaultExpiry(UserClaim) < DefaultExpiry(OperatorClaim), t)
+	AssertEquals(time.Duration(0), DefaultExpiry(GenericClaim), t)
+
+	old := DefaultExpiries[UserClaim]
+	defer func() { DefaultExpiries[UserClaim] = old }()
+	DefaultExpiries[UserClaim] = 0
+	uc = NewUserClaims(publicKey(createUserNKey(t), t))
+	AssertEquals(int64(0), uc.Expires, t)
+}
+
+func TestDeriveID(t *testing.T) {
+	apk := publicKey(createAccountNKey(t), t)
+	upk := publicKey(createUserNKey(t), t)
+	id := DeriveID(apk, upk, UserClaim)
+	AssertTrue(id != "", t)
+	AssertEquals(id, DeriveID(apk, upk, UserClaim), t)
+
+	ids := map[string]bool{id: true}
+	for _, other := range []string{
+		DeriveID(publicKey(createAccountNKey(t), t), upk, UserClaim),
+		DeriveID(apk, publicKey(createUserNKey(t), t), UserClaim),
+		DeriveID(apk, upk, AccountClaim),
+		DeriveID("ab", "c", UserClaim),
+		DeriveID("a", "bc", UserClaim),
+	} {
+		AssertFalse(ids[other], t)
+		ids[other] = true
+	}
+
+	// the derived key doesn't replace the token ID
+	akp := createAccountNKey(t)
+	uc := NewUserClaims(upk)
+	uc.ID = DeriveID(publicKey(akp, t), upk, UserClaim)
+	uc2, err := DecodeUserClaims(encode(uc, akp, t))
+	AssertNoError(err, t)
+	AssertTrue(uc2.ID != DeriveID(publicKey(akp, t), upk, UserClaim), t)
+}
+
+// resign re-signs the token with an earlier issue time and the matching ID,
+// like the same claim encoded at a different time
+func resign(token string, kp nkeys.KeyPair, t *testing.T) string {
+	chunks := strings.Split(token, ".")
+	uc, err := DecodeUserClaims(token)
+	AssertNoError(err, t)
+	uc.IssuedAt -= 100
+	uc.ID = ""
+	uc.ID, err = uc.hash()
+	AssertNoError(err, t)
+	payload, err := serialize(uc)
+	AssertNoError(err, t)
+	sig, err := kp.Sign([]byte(chunks[0] + "." + payload))
+	AssertNoError(err, t)
+	return chunks[0] + "." + payload + "." + encodeToString(sig)
+}
+
+func TestFindDuplicates(t *testing.T) {
+	akp := createAccountNKey(t)
+	uc := NewUserClaims(publicKey(createUserNKey(t), t))
+	uc.Pub.Allow.Add("orders.>")
+	token := encode(uc, akp, t)
+	copied := resign(token, akp, t)
+
+	other := NewUserClaims(publicKey(createUserNKey(t), t))
+	otherToken := encode(other, akp, t)
+
+	groups := FindDuplicates([]string{token, copied, otherToken, "garbage"})
+	AssertEquals(2, len(groups), t)
+	c1, err := DecodeUserClaims(token)
+	AssertNoError(err, t)
+	c2, err := DecodeUserClaims(copied)
+	AssertNoError(err, t)
+	AssertTrue(c1.ID != c2.ID, t)
+	c3, err := DecodeUserClaims(otherToken)
+	AssertNoError(err, t)
+	for _, ids := range groups {
+		switch len(ids) {
+		case 1:
+			AssertEquals(c3.ID, ids[0], t)
+		case 2:
+			AssertTrue((ids[0] == c1.ID && ids[1] == c2.ID) || (ids[0] == c2.ID && ids[1] == c1.ID), t)
+		default:
+			t.Fatalf("unexpected group %v", ids)
+		}
+	}
+}
+
+func TestUserAuthorizeTrace(t *testing.T) {
+	account := NewAccountClaims(publicKey(createAccountNKey(t), t))
+	account.BlockedSubjects.Add("$SYS.>")
+	user := NewUserClaims(publicKey(createUserNKey(t), t))
+	user.Pub.Allow.Add("$SYS.>", "orders.*")
+	user.Pub.Deny.Add("orders.secret")
+
+	blocked := user.AuthorizeTrace(&account.Account, AuthzPub, "$SYS.REQ")
+	AssertFalse(blocked.Allowed, t)
+	AssertTrue(blocked.Blocked, t)
+	AssertEquals(1, len(blocked.Rules), t)
+	AssertEquals(AuthzRule{Kind: AuthzRuleBlock, Entry: "$SYS.>", Matched: true}, *blocked.Decision, t)
+
+	denied := user.AuthorizeTrace(&account.Account, AuthzPub, "orders.secret")
+	AssertFalse(denied.Allowed, t)
+	AssertFalse(denied.Blocked, t)
+	AssertEquals(2, len(denied.Rules), t)
+	AssertEquals(AuthzRule{Kind: AuthzRuleBlock, Entry: "$SYS.>"}, denied.Rules[0], t)
+	AssertEquals(AuthzRule{Kind: AuthzRuleDeny, Entry: "orders.secret", Matched: true}, *denied.Decision, t)
+
+	allowed := user.AuthorizeTrace(&account.Account, AuthzPub, "orders.new")
+	AssertTrue(allowed.Allowed, t)
+	AssertEquals(4, len(allowed.Rules), t)
+	AssertEquals(AuthzRule{Kind: AuthzRuleAllow, Entry: "orders.*", Matched: true}, *allowed.Decision, t)
+
+	// without the account only the user's permissions apply
+	noAcct := user.AuthorizeTrace(nil, AuthzPub, "$SYS.REQ")
+	AssertTrue(noAcct.Allowed, t)
+	AssertFalse(noAcct.Blocked, t)
+
+	// a wildcard isn't allowed by a narrower allow
+	wide := user.AuthorizeTrace(nil, AuthzPub, "orders.>")
+	AssertFalse(wide.Allowed, t)
+	AssertTrue(wide.Decision.Kind == AuthzRuleDeny, t)
+
+	// an empty sub allow list permits all subjects
+	sub := user.AuthorizeTrace(&account.Account, AuthzSub, "anything")
+	AssertTrue(sub.Allowed, t)
+	AssertTrue(sub.Decision == nil, t)
+
+	// blocks covering the subject only together still block it
+	account.BlockedSubjects.Add("orders.*", "orders.*.>")
+	together := user.AuthorizeTrace(&account.Account, AuthzSub, "orders.>")
+	AssertFalse(together.Allowed, t)
+	AssertTrue(together.Blocked, t)
+	AssertTrue(together.Decision == nil, t)
+
+	unknown := user.AuthorizeTrace(&account.Account, "resp", "anything")
+	AssertFalse(unknown.Allowed, t)
+	AssertEquals(0, len(unknown.Rules), t)
+}